@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"pricing-api/pkg/search"
+	"time"
+)
+
+// runGaps implements the gaps subcommand, which prints the gap report of a
+// symbol as JSON. It returns the exit status: 0 when the data is clean, 1
+// when problems were found and 2 on errors.
+func runGaps(args []string) int {
+	fs := flag.NewFlagSet("gaps", flag.ContinueOnError)
+	assetClass := fs.String("asset-class", "", "asset class of the symbol")
+	symbol := fs.String("symbol", "", "symbol to examine")
+	interval := fs.String("interval", "1h", "candle interval to examine")
+	from := fs.String("from", "", "first day to examine, as 2006-01-02 or RFC 3339")
+	to := fs.String("to", "", "last day to examine, as 2006-01-02 or RFC 3339; defaults to from")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return 2
+	}
+	if *assetClass == "" || *symbol == "" || *from == "" {
+		fmt.Fprintln(os.Stderr, "gaps: -asset-class, -symbol and -from are required")
+		fs.Usage()
+		return 2
+	}
+	if *to == "" {
+		*to = *from
+	}
+	if err := search.Configure(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "gaps:", err)
+		return 2
+	}
+
+	start, err := parseDay(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gaps: invalid -from:", err)
+		return 2
+	}
+	end, err := parseDay(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gaps: invalid -to:", err)
+		return 2
+	}
+	if len(*to) == len("2006-01-02") {
+		end = end.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	report, err := search.AnalyzeGaps(context.Background(), *assetClass, *symbol, *interval, start, end)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gaps:", err)
+		return 2
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	fmt.Fprintln(os.Stderr, report)
+
+	if !report.Clean() {
+		return 1
+	}
+	return 0
+}
+
+// parseDay reads a date given as 2006-01-02 or in RFC 3339.
+func parseDay(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -1,13 +1,44 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"pricing-api/pkg/api"
+	"pricing-api/pkg/search"
+	"strings"
 )
 
 func main() {
-	router := api.SetupRouter()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gaps":
+			os.Exit(runGaps(os.Args[2:]))
+		case "hash-key":
+			os.Exit(runHashKey(os.Args[2:]))
+		}
+	}
+
+	keysPath := flag.String("keys", os.Getenv("PRICING_KEYS_FILE"), "path to the JSON API key file")
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := search.Configure(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if *keysPath == "" {
+		log.Fatal("No API key file configured; set -keys or PRICING_KEYS_FILE")
+	}
+	keys, err := api.LoadKeyStore(*keysPath)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	log.Printf("Serving data from %s", cfg.DataRoot)
+
+	router := api.SetupRouter(keys)
 	log.Fatal(http.ListenAndServe(":8080", router))
 
 	// basePath := "C:\\Users\\isvan\\OneDrive\\Documents\\work\\GoApi\\data"
@@ -19,3 +50,86 @@ func main() {
 	// }
 
 }
+
+// loadConfig builds the search config from, in increasing order of
+// precedence, the defaults, an optional JSON config file, environment
+// variables and the command line flags in args, parsed with fs. Flags of
+// their own can be defined on fs beforehand.
+func loadConfig(fs *flag.FlagSet, args []string) (search.Config, error) {
+	configPath := fs.String("config", os.Getenv("PRICING_CONFIG"), "path to a JSON config file")
+	dataRoot := fs.String("data", "", "root directory of the CSV data tree")
+	forexDir := fs.String("forex-dir", "", "forex subdirectory of the data root")
+	intervals := fs.String("intervals", "", "comma separated candle intervals in order of priority")
+	registryPath := fs.String("registry", "", "path to a JSON symbol registry file")
+	if err := fs.Parse(args); err != nil {
+		return search.Config{}, err
+	}
+
+	cfg := search.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		cfg, err = search.LoadConfig(*configPath)
+		if err != nil {
+			return search.Config{}, err
+		}
+	}
+
+	if v := os.Getenv("PRICING_DATA_ROOT"); v != "" {
+		cfg.DataRoot = v
+	}
+	if v := os.Getenv("PRICING_FOREX_DIR"); v != "" {
+		cfg.ForexDir = v
+	}
+	if v := os.Getenv("PRICING_INTERVALS"); v != "" {
+		cfg.Intervals = splitList(v)
+	}
+	if v := os.Getenv("PRICING_REGISTRY"); v != "" {
+		cfg.RegistryPath = v
+	}
+
+	if *dataRoot != "" {
+		cfg.DataRoot = *dataRoot
+	}
+	if *forexDir != "" {
+		cfg.ForexDir = *forexDir
+	}
+	if *intervals != "" {
+		cfg.Intervals = splitList(*intervals)
+	}
+	if *registryPath != "" {
+		cfg.RegistryPath = *registryPath
+	}
+
+	return cfg, nil
+}
+
+// runHashKey implements the hash-key subcommand. It prints the hash to put
+// in the key file for the key given as argument, or generates a new key and
+// prints both when none is given.
+func runHashKey(args []string) int {
+	switch len(args) {
+	case 0:
+		key, err := api.GenerateKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "hash-key:", err)
+			return 1
+		}
+		fmt.Printf("key:  %s\nhash: %s\n", key, api.HashKey(key))
+	case 1:
+		fmt.Println(api.HashKey(args[0]))
+	default:
+		fmt.Fprintln(os.Stderr, "usage: hash-key [key]")
+		return 2
+	}
+	return 0
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// keyReloadInterval is how often the key file is checked for changes, so
+// rotated keys take effect without a restart.
+const keyReloadInterval = time.Second
+
+// APIKey is one entry of the key file. Only the SHA-256 hash of the key is
+// stored.
+type APIKey struct {
+	// ID names the key in logs; it is not secret.
+	ID string `json:"id"`
+	// Hash is the hex SHA-256 of the key, as printed by HashKey.
+	Hash string `json:"hash"`
+	// Scopes lists the asset classes the key may read, "*" for all.
+	Scopes []string `json:"scopes"`
+	// Expires, when set, is when the key stops being accepted, so that an
+	// old key can stay valid for a while after its replacement is added.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Allows reports whether the key may read assetClass. A nil key may read
+// nothing.
+func (k *APIKey) Allows(assetClass string) bool {
+	if k == nil {
+		return false
+	}
+	for _, scope := range k.Scopes {
+		if scope == "*" || scope == assetClass {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore holds the API keys of a key file, reloading it when it changes.
+type KeyStore struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]*APIKey // by hash
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// LoadKeyStore reads the JSON key file at path, of the form
+// {"keys": [{"id": ..., "hash": ..., "scopes": [...]}]}.
+func LoadKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the key whose hash matches token, if it exists and has not
+// expired.
+func (s *KeyStore) Lookup(token string) (*APIKey, bool) {
+	s.refresh()
+
+	s.mu.RLock()
+	key, ok := s.keys[HashKey(token)]
+	s.mu.RUnlock()
+	if !ok || (key.Expires != nil && time.Now().After(*key.Expires)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// refresh reloads the key file if it changed since it was read, checking at
+// most once per keyReloadInterval. A file that fails to load is logged and
+// the keys already loaded are kept.
+func (s *KeyStore) refresh() {
+	s.mu.Lock()
+	if time.Since(s.checked) < keyReloadInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.checked = time.Now()
+	s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("Keeping API keys, cannot stat %s: %v", s.path, err)
+		return
+	}
+
+	s.mu.RLock()
+	changed := !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+	s.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := s.reload(); err != nil {
+		log.Printf("Keeping API keys: %v", err)
+		return
+	}
+	log.Printf("Reloaded API keys from %s", s.path)
+}
+
+func (s *KeyStore) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %v", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	var file struct {
+		Keys []*APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key file %s: %v", s.path, err)
+	}
+
+	keys := make(map[string]*APIKey, len(file.Keys))
+	for i, key := range file.Keys {
+		hash := strings.ToLower(key.Hash)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("key file %s: key %d (%s) has no valid SHA-256 hash", s.path, i, key.ID)
+		}
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("key file %s: key %d (%s) has no scopes", s.path, i, key.ID)
+		}
+		keys[hash] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime, s.size = info.ModTime(), info.Size()
+	s.mu.Unlock()
+	return nil
+}
+
+// HashKey returns the hex SHA-256 of an API key, as stored in the key file.
+func HashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// RequireAPIKey rejects requests without an "Authorization: Bearer <key>"
+// header naming a key of store, and passes the key on to the handlers in
+// the request context.
+func RequireAPIKey(store *KeyStore) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pricing-api"`)
+				writeError(w, &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: "an Authorization: Bearer header is required"})
+				return
+			}
+
+			key, ok := store.Lookup(strings.TrimSpace(token))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pricing-api", error="invalid_token"`)
+				writeError(w, &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: "the API key is invalid or expired"})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+		})
+	}
+}
+
+// requestKey returns the API key RequireAPIKey accepted for r.
+func requestKey(r *http.Request) *APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*APIKey)
+	return key
+}
+
+// authorized reports whether the key of r may read assetClass, answering
+// 403 Forbidden when it may not.
+func authorized(w http.ResponseWriter, r *http.Request, assetClass string) bool {
+	if !requestKey(r).Allows(assetClass) {
+		writeError(w, forbidden(assetClass))
+		return false
+	}
+	return true
+}
+
+// forbidden returns the error of a key that may not read assetClass.
+func forbidden(assetClass string) *APIError {
+	return &APIError{
+		Status:  http.StatusForbidden,
+		Code:    CodeForbidden,
+		Message: "the API key cannot read " + assetClass,
+		Details: map[string]interface{}{"assetClass": assetClass},
+	}
+}
+
+// allowedClasses returns the asset classes of classes the key of r may read.
+func allowedClasses(r *http.Request, classes []string) []string {
+	key := requestKey(r)
+	var allowed []string
+	for _, class := range classes {
+		if key.Allows(class) {
+			allowed = append(allowed, class)
+		}
+	}
+	return allowed
+}
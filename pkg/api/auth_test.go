@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"pricing-api/pkg/search"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeys(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, fmt.Sprintf(`{"keys": [
+		{"id": "crypto-reader", "hash": %q, "scopes": ["crypto"]},
+		{"id": "expired", "hash": %q, "scopes": ["*"], "expires": "2020-01-01T00:00:00Z"}
+	]}`, HashKey("crypto-key"), HashKey("old-key")))
+
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("LoadKeyStore returned error: %v", err)
+	}
+
+	handler := RequireAPIKey(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r, r.URL.Query().Get("assetClass")) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		assetClass string
+		want       int
+	}{
+		{name: "no header", assetClass: "crypto", want: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic crypto-key", assetClass: "crypto", want: http.StatusUnauthorized},
+		{name: "unknown key", header: "Bearer nope", assetClass: "crypto", want: http.StatusUnauthorized},
+		{name: "expired key", header: "Bearer old-key", assetClass: "crypto", want: http.StatusUnauthorized},
+		{name: "in scope", header: "Bearer crypto-key", assetClass: "crypto", want: http.StatusOK},
+		{name: "lower case scheme", header: "bearer crypto-key", assetClass: "crypto", want: http.StatusOK},
+		{name: "out of scope", header: "Bearer crypto-key", assetClass: "stocks", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?assetClass="+tt.assetClass, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyStoreReloadsRotatedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, fmt.Sprintf(`{"keys": [{"id": "a", "hash": %q, "scopes": ["*"]}]}`, HashKey("first")))
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("LoadKeyStore returned error: %v", err)
+	}
+	if _, ok := store.Lookup("first"); !ok {
+		t.Fatal("first key rejected")
+	}
+
+	writeKeys(t, path, fmt.Sprintf(`{"keys": [{"id": "b", "hash": %q, "scopes": ["*"]}]}`, HashKey("second")))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	store.checked = time.Time{}
+	store.mu.Unlock()
+
+	if _, ok := store.Lookup("second"); !ok {
+		t.Error("rotated key rejected")
+	}
+	if _, ok := store.Lookup("first"); ok {
+		t.Error("removed key still accepted")
+	}
+
+	// A broken file keeps the keys already loaded.
+	writeKeys(t, path, `{"keys": [`)
+	if err := os.Chtimes(path, later.Add(time.Hour), later.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	store.checked = time.Time{}
+	store.mu.Unlock()
+	if _, ok := store.Lookup("second"); !ok {
+		t.Error("key dropped after a failed reload")
+	}
+}
+
+func TestScopedKeyCannotLeaveItsAssetClass(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "equities", "all"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "equities", "all", "SECRET_USD.csv"), []byte("Date,Close\n2024-06-01T00:00:00Z,123\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := search.DefaultConfig()
+	cfg.DataRoot = root
+	newTestRouter(t, search.NewFileSource(cfg))
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, fmt.Sprintf(`{"keys": [{"id": "crypto-reader", "hash": %q, "scopes": ["crypto"]}]}`, HashKey("crypto-key")))
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := SetupRouter(store)
+
+	const symbol = "../../equities/all/SECRET_USD"
+	rangeQuery := "?assetClass=crypto&internalSymbol=" + url.QueryEscape(symbol) + "&startDate=2024-06-01T00:00:00Z&endDate=2024-06-02T00:00:00Z"
+	tests := []struct {
+		method, target, body string
+	}{
+		{method: "GET", target: "/getCloseUSD?assetClass=crypto&internalSymbol=" + url.QueryEscape(symbol) + "&date=2024-06-01T00:00:00Z"},
+		{method: "GET", target: "/getCandles" + rangeQuery},
+		{method: "GET", target: "/analytics" + rangeQuery},
+		{method: "POST", target: "/closes/batch", body: `{"items": [{"assetClass": "crypto", "internalSymbol": "` + symbol + `", "date": "2024-06-01T00:00:00Z"}]}`},
+		{method: "POST", target: "/portfolio/value", body: `{"date": "2024-06-01T00:00:00Z", "holdings": [{"assetClass": "crypto", "internalSymbol": "` + symbol + `", "quantity": 1}]}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer crypto-key")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if strings.Contains(rec.Body.String(), "123") {
+			t.Errorf("%s %s read the equities file: %s", tt.method, tt.target, rec.Body)
+		}
+		if rec.Code != http.StatusBadRequest && rec.Code != http.StatusForbidden && !strings.Contains(rec.Body.String(), `"code":"bad_request"`) {
+			t.Errorf("%s %s = %d %s, want the symbol rejected", tt.method, tt.target, rec.Code, rec.Body)
+		}
+	}
+}
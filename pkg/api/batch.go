@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"pricing-api/pkg/search"
+	"time"
+)
+
+// maxBatchItems bounds the number of lookups of one batch request.
+const maxBatchItems = 10000
+
+type BatchCloseItem struct {
+	AssetClass     string `json:"assetClass"`
+	InternalSymbol string `json:"internalSymbol"`
+	Date           string `json:"date"`
+}
+
+// BatchCloseRequest looks up the close of every item with the same lookup
+// options.
+type BatchCloseRequest struct {
+	Items []BatchCloseItem `json:"items"`
+	LookupParams
+
+	opts search.Options
+}
+
+func (req *BatchCloseRequest) validate() error {
+	if len(req.Items) == 0 {
+		return badRequest("items", "items is required")
+	}
+	if len(req.Items) > maxBatchItems {
+		return badRequest("items", fmt.Sprintf("at most %d items are allowed, got %d", maxBatchItems, len(req.Items)))
+	}
+
+	var err error
+	req.opts, err = req.options()
+	return err
+}
+
+// BatchCloseResult is the outcome of one item, holding either its result or
+// its error.
+type BatchCloseResult struct {
+	BatchCloseItem
+	Result *search.CloseUSDResponse `json:"result,omitempty"`
+	Error  *APIError                `json:"error,omitempty"`
+}
+
+type BatchCloseResponse struct {
+	Results []BatchCloseResult `json:"results"`
+}
+
+// BatchCloseHandler answers POST /closes/batch. Items that are invalid, out
+// of the API key's scopes or fail to look up get their own error; the
+// response lists one result per item in the order of the request.
+func BatchCloseHandler(w http.ResponseWriter, r *http.Request) {
+	var req BatchCloseRequest
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results := make([]BatchCloseResult, len(req.Items))
+	var lookups []search.CloseRequest
+	var positions []int
+	for i, item := range req.Items {
+		results[i].BatchCloseItem = item
+		if err := item.validate(r); err != nil {
+			results[i].Error = apiError(err)
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, item.Date)
+		lookups = append(lookups, search.CloseRequest{AssetClass: item.AssetClass, InternalSymbol: item.InternalSymbol, Date: date})
+		positions = append(positions, i)
+	}
+
+	for j, res := range search.GetCloseUSDBatch(r.Context(), lookups, req.opts) {
+		i := positions[j]
+		if res.Err != nil {
+			results[i].Error = apiError(res.Err)
+			continue
+		}
+		response := res.Response
+		results[i].Result = &response
+	}
+
+	writeJSON(w, BatchCloseResponse{Results: results})
+}
+
+// validate checks one item of a batch, including that the API key of r may
+// read its asset class.
+func (item BatchCloseItem) validate(r *http.Request) error {
+	if err := required("assetClass", item.AssetClass, "internalSymbol", item.InternalSymbol, "date", item.Date); err != nil {
+		return err
+	}
+	if err := names("assetClass", item.AssetClass, "internalSymbol", item.InternalSymbol); err != nil {
+		return err
+	}
+	if _, err := time.Parse(time.RFC3339, item.Date); err != nil {
+		return badRequest("date", fmt.Sprintf("invalid date format: %v", err))
+	}
+	if !requestKey(r).Allows(item.AssetClass) {
+		return forbidden(item.AssetClass)
+	}
+	return nil
+}
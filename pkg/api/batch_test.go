@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pricing-api/pkg/search"
+	"strings"
+	"testing"
+)
+
+func TestBatchCloseHandler(t *testing.T) {
+	src := search.NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h", search.Record{Date: "2024-06-01T00:00:00Z", Close: 0.45})
+	router := newTestRouter(t, src)
+
+	body := `{"items": [
+		{"assetClass": "crypto", "internalSymbol": "ADA_USDT", "date": "2024-06-01T00:00:00Z"},
+		{"assetClass": "crypto", "internalSymbol": "ADA_USDT"},
+		{"assetClass": "crypto", "internalSymbol": "NOPE_USDT", "date": "2024-06-01T00:00:00Z"},
+		{"assetClass": "crypto", "internalSymbol": "ADA_USDT", "date": "2024-06-01T00:00:00Z"}
+	]}`
+	req := httptest.NewRequest("POST", "/closes/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp BatchCloseResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 4 {
+		t.Fatalf("got %d results, want 4", len(resp.Results))
+	}
+
+	wantCodes := []string{"", CodeBadRequest, CodeUnknownSymbol, ""}
+	for i, res := range resp.Results {
+		if wantCodes[i] == "" {
+			if res.Error != nil || res.Result == nil || res.Result.ClosePrice != 0.45 {
+				t.Errorf("result %d = %+v, %+v, want close 0.45", i, res.Result, res.Error)
+			}
+			continue
+		}
+		if res.Error == nil || res.Error.Code != wantCodes[i] {
+			t.Errorf("result %d error = %+v, want %s", i, res.Error, wantCodes[i])
+		}
+	}
+}
+
+func TestBatchCloseHandlerRequiresItems(t *testing.T) {
+	router := newTestRouter(t, search.NewMemorySource())
+
+	req := httptest.NewRequest("POST", "/closes/batch", strings.NewReader(`{"items": []}`))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
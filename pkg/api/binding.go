@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxBodyBytes bounds the JSON bodies bind reads.
+const maxBodyBytes = 1 << 20
+
+// validator is implemented by request structs that check, and may complete,
+// their fields once bound.
+type validator interface {
+	validate() error
+}
+
+// bind fills the request struct pointed to by dst from r and validates it.
+// A JSON body is decoded first, for POST requests and for GET requests from
+// clients that still send one; query parameters named after the json tags
+// of dst are then applied on top.
+func bind(r *http.Request, dst interface{}) error {
+	if r.Body != nil && r.Body != http.NoBody {
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes))
+		if err := dec.Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+			return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: fmt.Sprintf("invalid JSON body: %v", err)}
+		}
+	}
+
+	if err := bindQuery(r.URL.Query(), reflect.ValueOf(dst).Elem()); err != nil {
+		return err
+	}
+
+	if v, ok := dst.(validator); ok {
+		return v.validate()
+	}
+	return nil
+}
+
+// bindQuery sets the exported fields of the struct v, including those of
+// embedded structs, from the query parameters named by their json tags.
+func bindQuery(query map[string][]string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindQuery(query, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		values, ok := query[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if err := setField(value, values[len(values)-1]); err != nil {
+			return badRequest(name, fmt.Sprintf("invalid %s %q: %v", name, values[len(values)-1], err))
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot be set from a query parameter")
+	}
+	return nil
+}
+
+// required returns an error naming the first of the given name and value
+// pairs whose value is empty.
+func required(fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if strings.TrimSpace(fields[i+1]) == "" {
+			return badRequest(fields[i], fields[i]+" is required")
+		}
+	}
+	return nil
+}
+
+// namePattern matches the asset classes, symbols and intervals of requests,
+// which name directories and files under the data root.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// names returns an error naming the first of the given name and value pairs
+// whose value is set but is not a plain name: one holding a path separator,
+// "..", or characters other than letters, digits, '_', '.' and '-'.
+func names(fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fields[i+1]
+		if value != "" && (!namePattern.MatchString(value) || strings.Contains(value, "..") || value == ".") {
+			return badRequest(fields[i], fmt.Sprintf("invalid %s %q", fields[i], value))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http/httptest"
+	"pricing-api/pkg/search"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBindGetCloseUSDRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		wantErr string
+	}{
+		{
+			name:   "query parameters",
+			method: "GET",
+			target: "/getCloseUSD?assetClass=crypto&internalSymbol=ADA_USDT&date=2024-06-01T00:00:00Z&strict=true&policy=previous&maxStaleness=1h",
+		},
+		{
+			name:   "JSON body",
+			method: "POST",
+			target: "/getCloseUSD",
+			body:   `{"assetClass":"crypto","internalSymbol":"ADA_USDT","date":"2024-06-01T00:00:00Z","strict":true,"policy":"previous","maxStaleness":"1h"}`,
+		},
+		{
+			name:   "query parameters override the body",
+			method: "GET",
+			target: "/getCloseUSD?internalSymbol=ADA_USDT&strict=1",
+			body:   `{"assetClass":"crypto","internalSymbol":"BTC_USDT","date":"2024-06-01T00:00:00Z","policy":"previous","maxStaleness":"1h"}`,
+		},
+		{name: "missing date", method: "GET", target: "/getCloseUSD?assetClass=crypto&internalSymbol=ADA_USDT", wantErr: "date is required"},
+		{name: "bad boolean", method: "GET", target: "/getCloseUSD?strict=maybe", wantErr: `invalid strict "maybe"`},
+		{name: "bad date", method: "GET", target: "/getCloseUSD?assetClass=crypto&internalSymbol=ADA_USDT&date=yesterday", wantErr: "invalid date format"},
+		{name: "bad JSON", method: "POST", target: "/getCloseUSD", body: `{"assetClass":`, wantErr: "invalid JSON body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			var req GetCloseUSDRequest
+			err := bind(r, &req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("bind error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bind returned error: %v", err)
+			}
+
+			want := search.Options{Strict: true, Policy: search.PolicyPrevious, MaxStaleness: time.Hour}
+			if req.AssetClass != "crypto" || req.InternalSymbol != "ADA_USDT" || !req.date.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) || req.opts != want {
+				t.Errorf("bound %+v", req)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"pricing-api/pkg/search"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type AssetClassesResponse struct {
+	AssetClasses []string `json:"assetClasses"`
+}
+
+type SymbolsResponse struct {
+	AssetClass string   `json:"assetClass"`
+	Symbols    []string `json:"symbols"`
+}
+
+type CoverageResponse struct {
+	Symbol   string            `json:"symbol"`
+	Coverage []search.Coverage `json:"coverage"`
+}
+
+func AssetClassesHandler(w http.ResponseWriter, r *http.Request) {
+	classes, err := search.AssetClasses(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, AssetClassesResponse{AssetClasses: nonNil(allowedClasses(r, classes))})
+}
+
+func SymbolsHandler(w http.ResponseWriter, r *http.Request) {
+	assetClass := r.URL.Query().Get("assetClass")
+	if assetClass == "" {
+		writeError(w, badRequest("assetClass", "assetClass is required"))
+		return
+	}
+	if err := names("assetClass", assetClass); err != nil {
+		writeError(w, err)
+		return
+	}
+	if !authorized(w, r, assetClass) {
+		return
+	}
+
+	symbols, err := search.Symbols(r.Context(), assetClass)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(symbols) == 0 {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "unknown asset class " + assetClass})
+		return
+	}
+
+	writeJSON(w, SymbolsResponse{AssetClass: assetClass, Symbols: symbols})
+}
+
+// CoverageHandler describes the candles held for the symbol of the path, in
+// the asset class given by the optional assetClass query parameter or in
+// every asset class holding it that the API key may read.
+func CoverageHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := mux.Vars(r)["symbol"]
+	assetClass := r.URL.Query().Get("assetClass")
+	if err := names("assetClass", assetClass, "symbol", symbol); err != nil {
+		writeError(w, err)
+		return
+	}
+	if assetClass != "" && !authorized(w, r, assetClass) {
+		return
+	}
+
+	found, err := search.SymbolCoverage(r.Context(), assetClass, symbol)
+	if err != nil && !errors.Is(err, search.ErrNoData) {
+		writeError(w, err)
+		return
+	}
+
+	coverage := []search.Coverage{}
+	for _, cov := range found {
+		if requestKey(r).Allows(cov.AssetClass) {
+			coverage = append(coverage, cov)
+		}
+	}
+	if len(coverage) == 0 {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: CodeUnknownSymbol, Message: "unknown symbol " + symbol})
+		return
+	}
+
+	writeJSON(w, CoverageResponse{Symbol: symbol, Coverage: coverage})
+}
+
+// GapsHandler reports the missing days and candles, duplicated timestamps
+// and misordered rows of the symbol of the path at the interval and over
+// the from and to dates given as query parameters.
+func GapsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	assetClass, interval := query.Get("assetClass"), query.Get("interval")
+	if err := required("assetClass", assetClass, "interval", interval); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := names("assetClass", assetClass, "symbol", mux.Vars(r)["symbol"], "interval", interval); err != nil {
+		writeError(w, err)
+		return
+	}
+	if !authorized(w, r, assetClass) {
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		writeError(w, badRequest("from", "invalid from date: "+err.Error()))
+		return
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		writeError(w, badRequest("to", "invalid to date: "+err.Error()))
+		return
+	}
+
+	report, err := search.AnalyzeGaps(r.Context(), assetClass, mux.Vars(r)["symbol"], interval, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// nonNil returns s, or an empty slice so that it encodes as [] rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"pricing-api/pkg/search"
+)
+
+// Error codes of the JSON error envelope.
+const (
+	CodeBadRequest    = "bad_request"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeUnknownSymbol = "unknown_symbol"
+	CodeNotFound      = "not_found"
+	CodeNoData        = "no_data"
+	CodeNoMatch       = "no_match"
+	CodeUnavailable   = "unavailable"
+	CodeInvalidData   = "invalid_data"
+	CodeInternal      = "internal"
+)
+
+// APIError is the body of every error response, sent as
+// {"error": {"code": ..., "message": ..., "details": ...}}.
+type APIError struct {
+	Status  int                    `json:"-"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+type errorEnvelope struct {
+	Error *APIError `json:"error"`
+}
+
+// fieldError is a request parameter that is missing or invalid.
+type fieldError struct {
+	field   string
+	message string
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// apiError maps err to the APIError sent for it: request errors are 400,
+// unknown symbols and missing data 404, candles failing the lookup policy
+// 422, cancelled or timed out requests 503 and anything else 500. Data
+// files that cannot be read are 500 too, with their own code and the
+// first bad value located in the details.
+func apiError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fieldErr *fieldError
+	var parseErr *search.ParseError
+	switch {
+	case errors.As(err, &fieldErr):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error(), Details: map[string]interface{}{"field": fieldErr.field}}
+	case errors.Is(err, search.ErrInvalidRequest):
+		return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error()}
+	case errors.Is(err, search.ErrUnknownSymbol):
+		return &APIError{Status: http.StatusNotFound, Code: CodeUnknownSymbol, Message: err.Error()}
+	case errors.Is(err, search.ErrNoData):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNoData, Message: err.Error()}
+	case errors.Is(err, search.ErrNoMatch):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeNoMatch, Message: err.Error()}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "the request was cancelled or timed out"}
+	case errors.As(err, &parseErr):
+		log.Printf("Invalid data: %v", err)
+		details := map[string]interface{}{"file": parseErr.File, "line": parseErr.Line}
+		if parseErr.Column != "" {
+			details["column"] = parseErr.Column
+		}
+		return &APIError{Status: http.StatusInternalServerError, Code: CodeInvalidData, Message: "a data file holds invalid values", Details: details}
+	}
+
+	log.Printf("Internal error: %v", err)
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal error"}
+}
+
+// writeError sends the JSON error envelope for err.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := apiError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: apiErr})
+}
+
+// badRequest returns the error of a missing or invalid request parameter,
+// sent as 400 with the parameter named in the details.
+func badRequest(field, message string) error {
+	return &fieldError{field: field, message: message}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"pricing-api/pkg/search"
+	"testing"
+)
+
+// newTestRouter returns the API router over src, accepting the key
+// "test-key" for every asset class.
+func newTestRouter(t *testing.T, src search.DataSource) http.Handler {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, fmt.Sprintf(`{"keys": [{"id": "test", "hash": %q, "scopes": ["*"]}]}`, HashKey("test-key")))
+	keys, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := search.Configure(search.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	search.SetDataSource(src)
+	// Configure puts back the default FileSource and an empty file cache.
+	t.Cleanup(func() { search.Configure(search.DefaultConfig()) })
+	return SetupRouter(keys)
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	src := search.NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h", search.Record{Date: "2024-06-01T00:00:00Z", Close: 0.45})
+	router := newTestRouter(t, src)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{
+			name:       "missing parameter",
+			target:     "/getCloseUSD?assetClass=crypto&internalSymbol=ADA_USDT",
+			wantStatus: http.StatusBadRequest, wantCode: CodeBadRequest, wantField: "date",
+		},
+		{
+			name:       "unknown symbol",
+			target:     "/getCloseUSD?assetClass=crypto&internalSymbol=NOPE_USDT&date=2024-06-01T00:00:00Z",
+			wantStatus: http.StatusNotFound, wantCode: CodeUnknownSymbol,
+		},
+		{
+			name:       "no data in window",
+			target:     "/getCloseUSD?assetClass=crypto&internalSymbol=ADA_USDT&date=2024-07-01T00:00:00Z",
+			wantStatus: http.StatusNotFound, wantCode: CodeNoData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("Authorization", "Bearer test-key")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var body errorEnvelope
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == nil {
+				t.Fatalf("response is not an error envelope: %v", err)
+			}
+			if rec.Code != tt.wantStatus || body.Error.Code != tt.wantCode {
+				t.Errorf("got %d %s (%s), want %d %s", rec.Code, body.Error.Code, body.Error.Message, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantField != "" && body.Error.Details["field"] != tt.wantField {
+				t.Errorf("details = %v, want field %s", body.Error.Details, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestAPIErrorStatuses(t *testing.T) {
+	parseErr := &search.ParseError{File: "crypto/all/ADA_USDT.csv", Line: 3, Column: "Close", Value: "abc", Err: errors.New("invalid syntax")}
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{fmt.Errorf("lookup: %w", search.ErrNoMatch), http.StatusUnprocessableEntity, CodeNoMatch},
+		{fmt.Errorf("%w: invalid interval", search.ErrInvalidRequest), http.StatusBadRequest, CodeBadRequest},
+		{fmt.Errorf("%w x: %w", search.ErrUnknownSymbol, search.ErrNoData), http.StatusNotFound, CodeUnknownSymbol},
+		{fmt.Errorf("reading: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeUnavailable},
+		{fmt.Errorf("disk on fire"), http.StatusInternalServerError, CodeInternal},
+		{fmt.Errorf("load: %w", parseErr), http.StatusInternalServerError, CodeInvalidData},
+	}
+	for _, tt := range tests {
+		got := apiError(tt.err)
+		if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+			t.Errorf("apiError(%v) = %d %s, want %d %s", tt.err, got.Status, got.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+	if got := apiError(fmt.Errorf("disk on fire")); got.Message != "internal error" {
+		t.Errorf("internal error message = %q, want it hidden", got.Message)
+	}
+	got := apiError(errors.Join(parseErr, fmt.Errorf("more")))
+	if got.Details["file"] != parseErr.File || got.Details["line"] != 3 || got.Details["column"] != "Close" {
+		t.Errorf("invalid data details = %v, want the file, line and column", got.Details)
+	}
+}
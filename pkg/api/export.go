@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"pricing-api/pkg/search"
+	"strconv"
+	"strings"
+)
+
+// Streamed export formats, selected by the Accept header of range requests.
+const (
+	formatCSV    = "text/csv"
+	formatNDJSON = "application/x-ndjson"
+)
+
+// flushEvery is the number of rows written between flushes of a stream.
+const flushEvery = 1000
+
+// exportFormat returns the streamed format accepted by r, or "" when the
+// client expects a single JSON document.
+func exportFormat(r *http.Request) string {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case formatCSV:
+			return formatCSV
+		case formatNDJSON, "application/ndjson":
+			return formatNDJSON
+		}
+	}
+	return ""
+}
+
+// exporter writes the rows of a streamed export, flushing them to the client
+// as it goes so that memory does not grow with the export.
+type exporter struct {
+	w       http.ResponseWriter
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+	rows    int
+}
+
+// newExporter starts a response in format, with header as the first row of
+// a CSV export.
+func newExporter(w http.ResponseWriter, format string, header []string) (*exporter, error) {
+	e := &exporter{w: w}
+	e.flusher, _ = w.(http.Flusher)
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if format == formatNDJSON {
+		e.json = json.NewEncoder(w)
+		return e, nil
+	}
+	e.csv = csv.NewWriter(w)
+	return e, e.csv.Write(header)
+}
+
+// write adds a row: v as a JSON line or fields as a CSV record.
+func (e *exporter) write(v interface{}, fields []string) error {
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(fields)
+	} else {
+		err = e.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%flushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+// finish flushes the export. An error met once the status was sent is
+// logged and, in NDJSON, sent as a last line holding the error envelope.
+// A CSV export has no room for one, so its response is aborted instead:
+// the client sees a truncated transfer rather than a complete file.
+func (e *exporter) finish(err error) {
+	if err != nil {
+		log.Printf("Export stopped after %d rows: %v", e.rows, err)
+		if e.json == nil {
+			e.flush()
+			panic(http.ErrAbortHandler)
+		}
+		e.json.Encode(errorEnvelope{Error: apiError(err)})
+	}
+	e.flush()
+}
+
+var closeColumns = []string{"date", "closePrice", "currency", "conversionRate", "conversionRateDate", "candle"}
+
+// exportCloses streams the closes of a /getCloseInBetween request.
+func exportCloses(w http.ResponseWriter, r *http.Request, req GetCloseInBetweenRequest, format string) {
+	stream, err := search.StreamCandles(r.Context(), req.AssetClass, req.InternalSymbol, req.start, req.end, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e, err := newExporter(w, format, closeColumns)
+	if err == nil {
+		err = stream.Closes(func(c search.ClosePriceDetail) error {
+			return e.write(c, []string{
+				c.Date, formatFloat(c.ClosePrice), stream.Currency,
+				formatFloat(c.Metadata.ConversionRate), c.Metadata.ConversionRateDate, c.Metadata.Candle,
+			})
+		})
+	}
+	e.finish(err)
+}
+
+var candleColumns = []string{"date", "open", "high", "low", "close", "volume", "quoteVolume", "currency", "conversionRate", "conversionRateDate", "candle"}
+
+// exportCandles streams the candles of a /getCandles request.
+func exportCandles(w http.ResponseWriter, r *http.Request, req GetCloseInBetweenRequest, format string) {
+	stream, err := search.StreamCandles(r.Context(), req.AssetClass, req.InternalSymbol, req.start, req.end, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e, err := newExporter(w, format, candleColumns)
+	if err == nil {
+		err = stream.Candles(func(c search.CandleDetail) error {
+			return e.write(c, []string{
+				c.Date, formatFloat(c.Open), formatFloat(c.High), formatFloat(c.Low), formatFloat(c.Close),
+				formatFloat(c.Volume), formatFloat(c.QuoteVolume), stream.Currency,
+				formatFloat(c.Metadata.ConversionRate), c.Metadata.ConversionRateDate, c.Metadata.Candle,
+			})
+		})
+	}
+	e.finish(err)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pricing-api/pkg/search"
+	"testing"
+	"time"
+)
+
+func exportSource() *search.MemorySource {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	records := make([]search.Record, 3*24*60)
+	for m := range records {
+		date := start.Add(time.Duration(m) * time.Minute).Format(time.RFC3339)
+		records[m] = search.Record{Date: date, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10}
+	}
+	src := search.NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1m", records...)
+	return src
+}
+
+func TestExportCSV(t *testing.T) {
+	router := newTestRouter(t, exportSource())
+
+	req := httptest.NewRequest("GET", "/getCandles?assetClass=crypto&internalSymbol=ADA_USDT&startDate=2024-06-01T00:00:00Z&endDate=2024-06-03T23:59:00Z", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != formatCSV {
+		t.Fatalf("got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if !rec.Flushed {
+		t.Error("export was not flushed")
+	}
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+3*24*60 {
+		t.Fatalf("got %d rows, want a header and %d candles", len(rows), 3*24*60)
+	}
+	if rows[0][0] != "date" || rows[1][0] != "2024-06-01T00:00:00Z" || rows[1][4] != "1.5" || rows[len(rows)-1][0] != "2024-06-03T23:59:00Z" {
+		t.Errorf("unexpected rows: %v ... %v", rows[:2], rows[len(rows)-1])
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	router := newTestRouter(t, exportSource())
+
+	req := httptest.NewRequest("GET", "/getCloseInBetween?assetClass=crypto&internalSymbol=ADA_USDT&startDate=2024-06-01T12:00:00Z&endDate=2024-06-02T12:00:00Z", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != formatNDJSON {
+		t.Fatalf("got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var detail search.ClosePriceDetail
+		if err := json.Unmarshal(scanner.Bytes(), &detail); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if detail.ClosePrice != 1.5 || detail.ClosePriceUSD == nil || *detail.ClosePriceUSD != 1.5 {
+			t.Errorf("line %d = %+v", lines+1, detail)
+		}
+		lines++
+	}
+	if lines != 24*60+1 {
+		t.Errorf("got %d lines, want %d", lines, 24*60+1)
+	}
+}
+
+func TestExportCSVAbortsOnError(t *testing.T) {
+	src := search.NewMemorySource()
+	src.Add("stocks", "SAP_EUR", "1d",
+		search.Record{Date: "2024-06-01T00:00:00Z", Close: 170},
+		search.Record{Date: "2024-06-20T00:00:00Z", Close: 171},
+	)
+	// No rate is recent enough for the second candle.
+	src.Add("forex", "EUR_USD", "1d", search.Record{Date: "2024-06-01T00:00:00Z", Close: 1.09})
+	server := httptest.NewServer(newTestRouter(t, src))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"/getCloseInBetween?assetClass=stocks&internalSymbol=SAP_EUR&startDate=2024-06-01T00:00:00Z&endDate=2024-06-20T00:00:00Z&quoteCurrency=USD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Accept", "text/csv")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, want the export to start", resp.StatusCode)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("a failed CSV export was read as complete")
+	}
+}
+
+func TestExportErrorsBeforeStreaming(t *testing.T) {
+	router := newTestRouter(t, exportSource())
+
+	req := httptest.NewRequest("GET", "/getCandles?assetClass=crypto&internalSymbol=NOPE_USDT&startDate=2024-06-01T00:00:00Z&endDate=2024-06-02T00:00:00Z", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body errorEnvelope
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == nil {
+		t.Fatalf("response is not an error envelope: %v", err)
+	}
+	if rec.Code != http.StatusNotFound || body.Error.Code != CodeUnknownSymbol {
+		t.Errorf("got %d %s, want %d %s", rec.Code, body.Error.Code, http.StatusNotFound, CodeUnknownSymbol)
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	tests := map[string]string{
+		"":                                       "",
+		"application/json":                       "",
+		"text/csv":                               formatCSV,
+		"text/csv; charset=utf-8":                formatCSV,
+		"application/json, application/x-ndjson": formatNDJSON,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := exportFormat(req); got != want {
+			t.Errorf("exportFormat(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
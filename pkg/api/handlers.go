@@ -2,15 +2,48 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"pricing-api/pkg/search"
+	"time"
+	"unicode"
 )
 
+// LookupParams are the lookup options shared by the price requests.
+type LookupParams struct {
+	Candle        string `json:"candle"`
+	Strict        bool   `json:"strict"`
+	Policy        string `json:"policy"`
+	MaxStaleness  string `json:"maxStaleness"`
+	QuoteCurrency string `json:"quoteCurrency"`
+}
+
 type GetCloseUSDRequest struct {
 	AssetClass     string `json:"assetClass"`
 	InternalSymbol string `json:"internalSymbol"`
 	Date           string `json:"date"`
-	Token          string `json:"token"`
+	LookupParams
+
+	date time.Time
+	opts search.Options
+}
+
+func (req *GetCloseUSDRequest) validate() error {
+	if err := required("assetClass", req.AssetClass, "internalSymbol", req.InternalSymbol, "date", req.Date); err != nil {
+		return err
+	}
+	if err := names("assetClass", req.AssetClass, "internalSymbol", req.InternalSymbol); err != nil {
+		return err
+	}
+
+	date, err := time.Parse(time.RFC3339, req.Date)
+	if err != nil {
+		return badRequest("date", fmt.Sprintf("invalid date format: %v", err))
+	}
+	req.date = date
+
+	req.opts, err = req.options()
+	return err
 }
 
 type GetCloseInBetweenRequest struct {
@@ -18,26 +51,52 @@ type GetCloseInBetweenRequest struct {
 	InternalSymbol string `json:"internalSymbol"`
 	StartDate      string `json:"startDate"`
 	EndDate        string `json:"endDate"`
-	Candle         string `json:"candle"`
-	Token          string `json:"token"`
+	LookupParams
+
+	start, end time.Time
+	opts       search.Options
+}
+
+func (req *GetCloseInBetweenRequest) validate() error {
+	if err := required("assetClass", req.AssetClass, "internalSymbol", req.InternalSymbol, "startDate", req.StartDate, "endDate", req.EndDate); err != nil {
+		return err
+	}
+	if err := names("assetClass", req.AssetClass, "internalSymbol", req.InternalSymbol); err != nil {
+		return err
+	}
+
+	start, err := time.Parse(time.RFC3339, req.StartDate)
+	if err != nil {
+		return badRequest("startDate", fmt.Sprintf("invalid start date format: %v", err))
+	}
+	end, err := time.Parse(time.RFC3339, req.EndDate)
+	if err != nil {
+		return badRequest("endDate", fmt.Sprintf("invalid end date format: %v", err))
+	}
+	if end.Before(start) {
+		return badRequest("endDate", "endDate is before startDate")
+	}
+	req.start, req.end = start, end
+
+	req.opts, err = req.options()
+	return err
 }
 
 func GetCloseUSDHandler(w http.ResponseWriter, r *http.Request) {
 	var req GetCloseUSDRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	if req.Token != "ACTUAL_TOKEN" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !authorized(w, r, req.AssetClass) {
 		return
 	}
 
 	//Searching for close price implementation.
-	result, err := search.GetCloseUSD(req.AssetClass, req.InternalSymbol, req.Date)
+	result, err := search.GetCloseUSD(r.Context(), req.AssetClass, req.InternalSymbol, req.date, req.opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -45,25 +104,134 @@ func GetCloseUSDHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// GetCloseInBetweenHandler answers /getCloseInBetween with one JSON
+// document, or streams the closes as CSV or NDJSON when the Accept header
+// asks for text/csv or application/x-ndjson.
 func GetCloseInBetweenHandler(w http.ResponseWriter, r *http.Request) {
 	var req GetCloseInBetweenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !authorized(w, r, req.AssetClass) {
+		return
+	}
+
+	if format := exportFormat(r); format != "" {
+		exportCloses(w, r, req, format)
+		return
+	}
+
+	result, err := search.GetCloseInBetween(r.Context(), req.AssetClass, req.InternalSymbol, req.start, req.end, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCandleHandler answers /getCandle, which takes the parameters of
+// /getCloseUSD and returns the whole candle the close comes from.
+func GetCandleHandler(w http.ResponseWriter, r *http.Request) {
+	var req GetCloseUSDRequest
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !authorized(w, r, req.AssetClass) {
+		return
+	}
+
+	result, err := search.GetCandle(r.Context(), req.AssetClass, req.InternalSymbol, req.date, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCandlesHandler answers /getCandles, which takes the parameters of
+// /getCloseInBetween and returns whole candles. Like /getCloseInBetween it
+// streams CSV or NDJSON when the Accept header asks for it.
+func GetCandlesHandler(w http.ResponseWriter, r *http.Request) {
+	var req GetCloseInBetweenRequest
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !authorized(w, r, req.AssetClass) {
+		return
+	}
+
+	if format := exportFormat(r); format != "" {
+		exportCandles(w, r, req, format)
+		return
+	}
+
+	result, err := search.GetCandles(r.Context(), req.AssetClass, req.InternalSymbol, req.start, req.end, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// AnalyticsHandler answers /analytics, which takes the parameters of
+// /getCloseInBetween and returns the returns, volatility, drawdown, range
+// and VWAP of the candles it selects.
+func AnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	var req GetCloseInBetweenRequest
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	//token validation
-	if req.Token != "ACTUAL_TOKEN" {
-		http.Error(w, "Unauthorised", http.StatusUnauthorized)
+	if !authorized(w, r, req.AssetClass) {
 		return
 	}
 
-	result, err := search.GetCloseInBetween(req.AssetClass, req.InternalSymbol, req.StartDate, req.EndDate, req.Candle)
+	result, err := search.GetAnalytics(r.Context(), req.AssetClass, req.InternalSymbol, req.start, req.end, req.opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// options builds search.Options from the lookup parameters.
+func (p LookupParams) options() (search.Options, error) {
+	policy, err := search.ParseLookupPolicy(p.Policy)
+	if err != nil {
+		return search.Options{}, badRequest("policy", err.Error())
+	}
+
+	var staleness time.Duration
+	if p.MaxStaleness != "" {
+		staleness, err = time.ParseDuration(p.MaxStaleness)
+		if err != nil || staleness < 0 {
+			return search.Options{}, badRequest("maxStaleness", fmt.Sprintf("invalid maxStaleness %q", p.MaxStaleness))
+		}
+	}
+
+	if err := names("candle", p.Candle); err != nil {
+		return search.Options{}, err
+	}
+	for _, c := range p.QuoteCurrency {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return search.Options{}, badRequest("quoteCurrency", fmt.Sprintf("invalid quoteCurrency %q", p.QuoteCurrency))
+		}
+	}
+
+	return search.Options{Candle: p.Candle, Strict: p.Strict, Policy: policy, MaxStaleness: staleness, QuoteCurrency: p.QuoteCurrency}, nil
+}
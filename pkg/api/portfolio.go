@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"pricing-api/pkg/search"
+	"time"
+)
+
+// maxHoldings bounds the number of holdings of one portfolio request.
+const maxHoldings = 10000
+
+// PortfolioRequest values holdings at date.
+type PortfolioRequest struct {
+	Holdings []search.Holding `json:"holdings"`
+	Date     string           `json:"date"`
+	LookupParams
+
+	date time.Time
+	opts search.Options
+}
+
+func (req *PortfolioRequest) validate() error {
+	if err := required("date", req.Date); err != nil {
+		return err
+	}
+	if len(req.Holdings) == 0 {
+		return badRequest("holdings", "holdings is required")
+	}
+	if len(req.Holdings) > maxHoldings {
+		return badRequest("holdings", fmt.Sprintf("at most %d holdings are allowed, got %d", maxHoldings, len(req.Holdings)))
+	}
+	for i, h := range req.Holdings {
+		field := fmt.Sprintf("holdings[%d]", i)
+		if err := required(field+".assetClass", h.AssetClass, field+".internalSymbol", h.InternalSymbol); err != nil {
+			return err
+		}
+		if err := names(field+".assetClass", h.AssetClass, field+".internalSymbol", h.InternalSymbol); err != nil {
+			return err
+		}
+		if math.IsNaN(h.Quantity) || math.IsInf(h.Quantity, 0) {
+			return badRequest(field+".quantity", "quantity must be a finite number")
+		}
+	}
+
+	date, err := time.Parse(time.RFC3339, req.Date)
+	if err != nil {
+		return badRequest("date", fmt.Sprintf("invalid date format: %v", err))
+	}
+	req.date = date
+
+	req.opts, err = req.options()
+	return err
+}
+
+// PortfolioPosition is a valued position, or the error that prevented
+// valuing it.
+type PortfolioPosition struct {
+	search.Position
+	Error *APIError `json:"error,omitempty"`
+}
+
+type PortfolioResponse struct {
+	search.Valuation
+	Positions []PortfolioPosition `json:"positions"`
+}
+
+// PortfolioHandler answers POST /portfolio/value. The API key must be
+// allowed every asset class of the portfolio, so that a total never hides
+// positions the caller cannot see.
+func PortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	var req PortfolioRequest
+	if err := bind(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for _, h := range req.Holdings {
+		if !authorized(w, r, h.AssetClass) {
+			return
+		}
+	}
+
+	valuation, err := search.ValuePortfolio(r.Context(), req.Holdings, req.date, req.opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := PortfolioResponse{Valuation: valuation, Positions: make([]PortfolioPosition, len(valuation.Positions))}
+	for i, pos := range valuation.Positions {
+		resp.Positions[i].Position = pos
+		if pos.Err != nil {
+			resp.Positions[i].Error = apiError(pos.Err)
+		}
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pricing-api/pkg/search"
+	"strings"
+	"testing"
+)
+
+func TestPortfolioHandler(t *testing.T) {
+	src := search.NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h", search.Record{Date: "2024-06-01T00:00:00Z", Close: 0.5})
+	router := newTestRouter(t, src)
+
+	body := `{"date": "2024-06-01T00:00:00Z", "holdings": [
+		{"assetClass": "crypto", "internalSymbol": "ADA_USDT", "quantity": 100},
+		{"assetClass": "crypto", "internalSymbol": "NOPE_USDT", "quantity": 1}
+	]}`
+	req := httptest.NewRequest("POST", "/portfolio/value", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Total     float64 `json:"total"`
+		Complete  bool    `json:"complete"`
+		Positions []struct {
+			Value float64   `json:"value"`
+			Error *APIError `json:"error"`
+		} `json:"positions"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 50 || resp.Complete || len(resp.Positions) != 2 {
+		t.Fatalf("response = %+v, want an incomplete total of 50 over 2 positions", resp)
+	}
+	if resp.Positions[0].Value != 50 || resp.Positions[0].Error != nil {
+		t.Errorf("first position = %+v, want a value of 50", resp.Positions[0])
+	}
+	if err := resp.Positions[1].Error; err == nil || err.Code != CodeUnknownSymbol {
+		t.Errorf("second position error = %+v, want %s", err, CodeUnknownSymbol)
+	}
+}
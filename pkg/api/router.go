@@ -4,9 +4,20 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func SetupRouter() *mux.Router {
+// SetupRouter returns the API routes, each requiring a key of keys.
+func SetupRouter(keys *KeyStore) *mux.Router {
 	router := mux.NewRouter()
-	router.HandleFunc("/getCloseUSD", GetCloseUSDHandler).Methods("GET")
-	router.HandleFunc("/getCloseInBetween", GetCloseInBetweenHandler).Methods("GET")
+	router.Use(RequireAPIKey(keys))
+	router.HandleFunc("/getCloseUSD", GetCloseUSDHandler).Methods("GET", "POST")
+	router.HandleFunc("/getCloseInBetween", GetCloseInBetweenHandler).Methods("GET", "POST")
+	router.HandleFunc("/getCandle", GetCandleHandler).Methods("GET", "POST")
+	router.HandleFunc("/getCandles", GetCandlesHandler).Methods("GET", "POST")
+	router.HandleFunc("/analytics", AnalyticsHandler).Methods("GET", "POST")
+	router.HandleFunc("/closes/batch", BatchCloseHandler).Methods("POST")
+	router.HandleFunc("/portfolio/value", PortfolioHandler).Methods("POST")
+	router.HandleFunc("/assetClasses", AssetClassesHandler).Methods("GET")
+	router.HandleFunc("/symbols", SymbolsHandler).Methods("GET")
+	router.HandleFunc("/symbols/{symbol}/coverage", CoverageHandler).Methods("GET")
+	router.HandleFunc("/symbols/{symbol}/gaps", GapsHandler).Methods("GET")
 	return router
 }
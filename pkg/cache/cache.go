@@ -0,0 +1,195 @@
+// Package cache keeps the parsed contents of files in memory, bounded by
+// total size and entry count and evicted least recently used first.
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Loader parses the file at path, returning the parsed value and an
+// estimate of the memory it occupies in bytes.
+type Loader[V any] func(path string) (V, int64, error)
+
+// Stats reports the activity of a Cache.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	Bytes         int64  `json:"bytes"`
+}
+
+// Cache is an LRU cache of parsed files keyed by path. An entry is only
+// served while the file's modification time and size are unchanged, and
+// concurrent misses for the same file version share a single load. A file
+// that fails to parse is cached with its error, so that it is not parsed
+// again until it changes.
+type Cache[V any] struct {
+	maxBytes   int64
+	maxEntries int
+
+	mu       sync.Mutex
+	order    *list.List // front is most recently used
+	entries  map[string]*list.Element
+	inflight map[version]*call[V]
+	stats    Stats
+}
+
+// version identifies one state of a file on disk.
+type version struct {
+	path    string
+	modTime int64 // UnixNano
+	size    int64
+}
+
+type entry[V any] struct {
+	version
+	value V
+	err   error
+	bytes int64
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New returns a Cache holding at most maxBytes of parsed data in at most
+// maxEntries files. A limit of zero or less disables that bound.
+func New[V any](maxBytes int64, maxEntries int) *Cache[V] {
+	return &Cache[V]{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		inflight:   make(map[version]*call[V]),
+	}
+}
+
+// Get returns the parsed contents of the file at path, calling load when
+// the file is not cached or has changed since it was cached. Errors from
+// os.Stat are returned as is, so callers can test them with os.IsNotExist.
+// Errors of load are cached along with the file version, except for
+// *fs.PathError values, which report a failure to read the file that may
+// not last.
+func (c *Cache[V]) Get(path string, load Loader[V]) (V, error) {
+	var zero V
+
+	info, err := os.Stat(path)
+	if err != nil {
+		c.Invalidate(path)
+		return zero, err
+	}
+	v := version{path: path, modTime: info.ModTime().UnixNano(), size: info.Size()}
+
+	c.mu.Lock()
+	if el, ok := c.entries[path]; ok {
+		e := el.Value.(*entry[V])
+		if e.version == v {
+			c.order.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.value, e.err
+		}
+		c.remove(el)
+		c.stats.Invalidations++
+	}
+	c.stats.Misses++
+
+	if cl, ok := c.inflight[v]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.value, cl.err
+	}
+	cl := &call[V]{done: make(chan struct{})}
+	c.inflight[v] = cl
+	c.mu.Unlock()
+
+	value, bytes, err := load(path)
+	cl.value, cl.err = value, err
+
+	c.mu.Lock()
+	delete(c.inflight, v)
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		c.add(&entry[V]{version: v, value: value, err: err, bytes: bytes})
+	}
+	c.mu.Unlock()
+	close(cl.done)
+
+	return value, err
+}
+
+// Invalidate drops the cached contents of path, if any.
+func (c *Cache[V]) Invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		c.remove(el)
+		c.stats.Invalidations++
+	}
+}
+
+// Purge drops every cached entry.
+func (c *Cache[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, el := range c.entries {
+		c.remove(el)
+	}
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// add stores e, replacing any older entry for the same path, then evicts
+// least recently used entries until the cache is within its bounds. An
+// entry larger than maxBytes on its own is not stored. c.mu must be held.
+func (c *Cache[V]) add(e *entry[V]) {
+	if c.maxBytes > 0 && e.bytes > c.maxBytes {
+		return
+	}
+	if el, ok := c.entries[e.path]; ok {
+		if el.Value.(*entry[V]).modTime > e.modTime {
+			return // a newer version was loaded meanwhile
+		}
+		c.remove(el)
+	}
+
+	c.entries[e.path] = c.order.PushFront(e)
+	c.stats.Bytes += e.bytes
+
+	for c.overLimit() {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache[V]) overLimit() bool {
+	if c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		return true
+	}
+	return c.maxBytes > 0 && c.stats.Bytes > c.maxBytes
+}
+
+// remove drops el from the cache. c.mu must be held.
+func (c *Cache[V]) remove(el *list.Element) {
+	e := c.order.Remove(el).(*entry[V])
+	delete(c.entries, e.path)
+	c.stats.Bytes -= e.bytes
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLoader(loads *int32) Loader[string] {
+	return func(path string) (string, int64, error) {
+		atomic.AddInt32(loads, 1)
+		data, err := os.ReadFile(path)
+		return string(data), int64(len(data)), err
+	}
+}
+
+func TestGetCachesUntilFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.csv")
+	modTime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, path, "one", modTime)
+
+	var loads int32
+	c := New[string](0, 0)
+	for i := 0; i < 3; i++ {
+		got, err := c.Get(path, readLoader(&loads))
+		if err != nil || got != "one" {
+			t.Fatalf("Get = %q, %v", got, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	writeFile(t, path, "two", modTime.Add(time.Second))
+	got, err := c.Get(path, readLoader(&loads))
+	if err != nil || got != "two" {
+		t.Fatalf("Get after change = %q, %v", got, err)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Invalidations != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	os.Remove(path)
+	if _, err := c.Get(path, readLoader(&loads)); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if c.Stats().Entries != 0 {
+		t.Errorf("removed file is still cached")
+	}
+}
+
+func TestGetEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	paths := make([]string, 4)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, string(rune('a'+i))+".csv")
+		writeFile(t, paths[i], "12345", modTime)
+	}
+
+	var loads int32
+	c := New[string](15, 0) // room for three files
+	for _, p := range paths[:3] {
+		c.Get(p, readLoader(&loads))
+	}
+	c.Get(paths[0], readLoader(&loads)) // paths[1] is now least recently used
+	c.Get(paths[3], readLoader(&loads))
+
+	stats := c.Stats()
+	if stats.Entries != 3 || stats.Bytes != 15 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	loads = 0
+	c.Get(paths[0], readLoader(&loads))
+	c.Get(paths[1], readLoader(&loads))
+	if loads != 1 {
+		t.Errorf("expected only the evicted file to be reloaded, got %d loads", loads)
+	}
+
+	byCount := New[string](0, 1)
+	byCount.Get(paths[0], readLoader(&loads))
+	byCount.Get(paths[1], readLoader(&loads))
+	if stats := byCount.Stats(); stats.Entries != 1 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats with an entry limit: %+v", stats)
+	}
+}
+
+func TestGetLoadsConcurrentMissesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.csv")
+	writeFile(t, path, "one", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(path string) (string, int64, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "parsed", 6, nil
+	}
+
+	c := New[string](0, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := c.Get(path, load); err != nil || got != "parsed" {
+				t.Errorf("Get = %q, %v", got, err)
+			}
+		}()
+	}
+
+	// Give the goroutines time to queue behind the first load.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+}
+
+func TestGetCachesFailedLoadsUntilFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.csv")
+	modTime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, path, "bad", modTime)
+
+	var loads int32
+	load := func(path string) (string, int64, error) {
+		atomic.AddInt32(&loads, 1)
+		data, err := os.ReadFile(path)
+		if err == nil && string(data) == "bad" {
+			err = errors.New("cannot parse")
+		}
+		return string(data), int64(len(data)), err
+	}
+
+	c := New[string](0, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(path, load); err == nil || err.Error() != "cannot parse" {
+			t.Fatalf("Get = %v, want the parse error", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	writeFile(t, path, "good", modTime.Add(time.Second))
+	if got, err := c.Get(path, load); err != nil || got != "good" {
+		t.Fatalf("Get after a fix = %q, %v", got, err)
+	}
+
+	// Failures to read the file are not cached.
+	failing := func(path string) (string, int64, error) {
+		atomic.AddInt32(&loads, 1)
+		return "", 0, &fs.PathError{Op: "read", Path: path, Err: errors.New("i/o error")}
+	}
+	c.Purge()
+	loads = 0
+	for i := 0; i < 2; i++ {
+		c.Get(path, failing)
+	}
+	if loads != 2 {
+		t.Errorf("read failures loaded %d times, want 2", loads)
+	}
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// year is the length used to annualize volatility.
+const year = 365.25 * 24 * time.Hour
+
+// Analytics summarizes the performance of a symbol over a range, computed
+// from every candle of the range with its prices converted to the quote
+// currency at the candle's own date.
+type Analytics struct {
+	AssetClass     string `json:"assetClass"`
+	InternalSymbol string `json:"internalSymbol"`
+	Currency       string `json:"currency"`
+	Candle         string `json:"candle"`
+	ResampledFrom  string `json:"resampledFrom,omitempty"`
+	// Candles is the number of candles the figures are computed from.
+	Candles int        `json:"candles"`
+	Start   PricePoint `json:"start"`
+	End     PricePoint `json:"end"`
+	// SimpleReturn is End over Start minus one, and LogReturn its natural
+	// logarithm counterpart.
+	SimpleReturn float64 `json:"simpleReturn"`
+	LogReturn    float64 `json:"logReturn"`
+	// Volatility is the sample standard deviation of the log returns
+	// between consecutive candles, annualized by the average spacing of
+	// the candles. It is zero for fewer than three candles.
+	Volatility  float64  `json:"volatility"`
+	MaxDrawdown Drawdown `json:"maxDrawdown"`
+	// High and Low are the extreme highs and lows of the candles.
+	High PricePoint `json:"high"`
+	Low  PricePoint `json:"low"`
+	// VWAP weighs the typical price of each candle, the mean of its high,
+	// low and close, by its volume. It is absent when no volume traded.
+	VWAP *float64 `json:"vwap,omitempty"`
+}
+
+// PricePoint is a price and the date of the candle it comes from.
+type PricePoint struct {
+	Date  string  `json:"date"`
+	Price float64 `json:"price"`
+}
+
+// Drawdown is the largest fall of the close from a running peak, as a
+// fraction of the peak.
+type Drawdown struct {
+	Value  float64    `json:"value"`
+	Peak   PricePoint `json:"peak"`
+	Trough PricePoint `json:"trough"`
+}
+
+// GetAnalytics computes the Analytics of internalSymbol between start and
+// end, selecting candles as GetCloseInBetween does.
+func GetAnalytics(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options) (Analytics, error) {
+	if err := checkRange(start, end); err != nil {
+		return Analytics{}, err
+	}
+
+	rng, err := closeRange(ctx, assetClass, internalSymbol, start, end, opts)
+	if err != nil {
+		return Analytics{}, err
+	}
+
+	for i, row := range rng.records {
+		if rng.conversions[i].Amount <= 0 {
+			return Analytics{}, fmt.Errorf("%w: non-positive close %v at %s", ErrNoMatch, rng.conversions[i].Amount, row.Date)
+		}
+	}
+	return analyze(assetClass, internalSymbol, rng), nil
+}
+
+func analyze(assetClass, internalSymbol string, rng convertedRange) Analytics {
+	records, conversions := rng.records, rng.conversions
+	n := len(records)
+	point := func(i int, price float64) PricePoint {
+		return PricePoint{Date: records[i].Date, Price: price}
+	}
+
+	a := Analytics{
+		AssetClass:     assetClass,
+		InternalSymbol: internalSymbol,
+		Currency:       rng.currency,
+		Candle:         rng.interval,
+		ResampledFrom:  rng.resampledFrom,
+		Candles:        n,
+		Start:          point(0, conversions[0].Amount),
+		End:            point(n-1, conversions[n-1].Amount),
+	}
+	a.SimpleReturn = a.End.Price/a.Start.Price - 1
+	a.LogReturn = math.Log(a.End.Price / a.Start.Price)
+	a.Volatility = annualizedVolatility(records, conversions)
+
+	peak := a.Start
+	a.MaxDrawdown = Drawdown{Peak: peak, Trough: peak}
+	_, high, low := records[0].prices()
+	a.High, a.Low = point(0, high*conversions[0].Rate), point(0, low*conversions[0].Rate)
+	var turnover, volume float64
+	for i, row := range records {
+		rate, close := conversions[i].Rate, conversions[i].Amount
+		_, high, low := row.prices()
+
+		if close > peak.Price {
+			peak = point(i, close)
+		} else if dd := 1 - close/peak.Price; dd > a.MaxDrawdown.Value {
+			a.MaxDrawdown = Drawdown{Value: dd, Peak: peak, Trough: point(i, close)}
+		}
+
+		if high*rate > a.High.Price {
+			a.High = point(i, high*rate)
+		}
+		if low*rate < a.Low.Price {
+			a.Low = point(i, low*rate)
+		}
+
+		turnover += (high + low + row.Close) / 3 * rate * row.Volume
+		volume += row.Volume
+	}
+	if volume > 0 {
+		vwap := turnover / volume
+		a.VWAP = &vwap
+	}
+	return a
+}
+
+// annualizedVolatility returns the sample standard deviation of the log
+// returns between consecutive closes, scaled to a year by the average
+// spacing of the candles, which accounts for sessions that do not trade
+// around the clock.
+func annualizedVolatility(records []Record, conversions []Conversion) float64 {
+	n := len(records)
+	if n < 3 {
+		return 0
+	}
+
+	returns := make([]float64, n-1)
+	var mean float64
+	for i := 1; i < n; i++ {
+		returns[i-1] = math.Log(conversions[i].Amount / conversions[i-1].Amount)
+		mean += returns[i-1]
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	spacing := records[n-1].Time.Sub(records[0].Time) / time.Duration(n-1)
+	if spacing <= 0 {
+		return 0
+	}
+	return math.Sqrt(variance * float64(year) / float64(spacing))
+}
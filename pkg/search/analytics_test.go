@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetAnalytics(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1d",
+		Record{Date: "2024-06-01T00:00:00Z", High: 1.1, Low: 0.9, Close: 1.0, Volume: 100},
+		Record{Date: "2024-06-02T00:00:00Z", High: 1.3, Low: 1.0, Close: 1.2, Volume: 300},
+		Record{Date: "2024-06-03T00:00:00Z", High: 1.2, Low: 0.8, Close: 0.9, Volume: 100},
+		Record{Date: "2024-06-04T00:00:00Z", High: 1.1, Low: 0.9, Close: 1.1, Volume: 0},
+	)
+	withSource(t, src)
+
+	a, err := GetAnalytics(context.Background(), "crypto", "ADA_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-04T00:00:00Z"), Options{Candle: "1d", Policy: PolicyExact})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if a.Candles != 4 || a.Start.Price != 1.0 || a.End.Price != 1.1 {
+		t.Errorf("candles %d from %v to %v, want 4 from 1.0 to 1.1", a.Candles, a.Start, a.End)
+	}
+	if !near(a.SimpleReturn, 0.1) || !near(a.LogReturn, math.Log(1.1)) {
+		t.Errorf("returns = %v, %v, want 0.1, ln(1.1)", a.SimpleReturn, a.LogReturn)
+	}
+	if !near(a.MaxDrawdown.Value, 0.25) || a.MaxDrawdown.Peak.Date != "2024-06-02T00:00:00Z" || a.MaxDrawdown.Trough.Date != "2024-06-03T00:00:00Z" {
+		t.Errorf("max drawdown = %+v, want 0.25 from 2024-06-02 to 2024-06-03", a.MaxDrawdown)
+	}
+	if a.High != (PricePoint{Date: "2024-06-02T00:00:00Z", Price: 1.3}) || a.Low != (PricePoint{Date: "2024-06-03T00:00:00Z", Price: 0.8}) {
+		t.Errorf("high %+v, low %+v", a.High, a.Low)
+	}
+
+	wantVWAP := (1.0*100 + 3.5/3*300 + 2.9/3*100) / 500
+	if a.VWAP == nil || !near(*a.VWAP, wantVWAP) {
+		t.Errorf("vwap = %v, want %v", a.VWAP, wantVWAP)
+	}
+
+	returns := []float64{math.Log(1.2), math.Log(0.9 / 1.2), math.Log(1.1 / 0.9)}
+	mean := (returns[0] + returns[1] + returns[2]) / 3
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	if want := math.Sqrt(variance / 2 * 365.25); !near(a.Volatility, want) {
+		t.Errorf("volatility = %v, want %v", a.Volatility, want)
+	}
+}
+
+func TestAnalyticsExtremesFollowColumns(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, body string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("crypto/all/ZERO_USDT.csv", "Date,High,Low,Close\n"+
+		"2024-06-01T00:00:00Z,2,0,1\n"+
+		"2024-06-02T00:00:00Z,3,1,2\n")
+	write("crypto/all/BARE_USDT.csv", "Date,Close\n"+
+		"2024-06-01T00:00:00Z,1\n"+
+		"2024-06-02T00:00:00Z,2\n")
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	withSource(t, NewFileSource(cfg))
+
+	tests := []struct {
+		symbol            string
+		wantHigh, wantLow float64
+	}{
+		{symbol: "ZERO_USDT", wantHigh: 3, wantLow: 0},
+		{symbol: "BARE_USDT", wantHigh: 2, wantLow: 1},
+	}
+	for _, tt := range tests {
+		a, err := GetAnalytics(context.Background(), "crypto", tt.symbol, mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-02T00:00:00Z"), Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.symbol, err)
+		}
+		if a.High.Price != tt.wantHigh || a.Low.Price != tt.wantLow {
+			t.Errorf("%s: high %v, low %v, want %v and %v", tt.symbol, a.High.Price, a.Low.Price, tt.wantHigh, tt.wantLow)
+		}
+	}
+}
+
+func TestAnalyticsRejectsNonPositiveCloses(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "DEAD_USDT", "1d",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 1},
+		Record{Date: "2024-06-02T00:00:00Z", Close: 0},
+	)
+	withSource(t, src)
+
+	_, err := GetAnalytics(context.Background(), "crypto", "DEAD_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-02T00:00:00Z"), Options{})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("error = %v, want ErrNoMatch", err)
+	}
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CloseRequest is one lookup of a batch.
+type CloseRequest struct {
+	AssetClass     string
+	InternalSymbol string
+	Date           time.Time
+}
+
+// BatchResult is the outcome of one lookup of a batch: its response, or the
+// error that prevented it.
+type BatchResult struct {
+	Response CloseUSDResponse
+	Err      error
+}
+
+// GetCloseUSDBatch runs GetCloseUSD for every request with opts, on at most
+// Config.BatchWorkers goroutines, and returns the results in the order of
+// requests. The lookups share the parsed file cache and one FXConverter,
+// so candles and forex series read by one are not read again by the
+// others. Requests not started when ctx is done fail with its error.
+func GetCloseUSDBatch(ctx context.Context, requests []CloseRequest, opts Options) []BatchResult {
+	results := make([]BatchResult, len(requests))
+
+	workers := config.BatchWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(requests) {
+		workers = len(requests)
+	}
+
+	fx := converter()
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				req := requests[i]
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Response, results[i].Err = getCloseUSD(ctx, fx, req.AssetClass, req.InternalSymbol, req.Date, opts)
+			}
+		}()
+	}
+
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetCloseUSDBatchKeepsInputOrder(t *testing.T) {
+	src := NewMemorySource()
+	for i := 0; i < 24; i++ {
+		date := time.Date(2024, 6, 1, i, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		src.Add("crypto", "ADA_USDT", "1h", Record{Date: date, Close: float64(i)})
+	}
+	withSource(t, src)
+
+	var requests []CloseRequest
+	for i := 23; i >= 0; i-- {
+		requests = append(requests, CloseRequest{AssetClass: "crypto", InternalSymbol: "ADA_USDT", Date: time.Date(2024, 6, 1, i, 0, 0, 0, time.UTC)})
+	}
+	requests = append(requests, CloseRequest{AssetClass: "crypto", InternalSymbol: "NOPE_USDT", Date: requests[0].Date})
+
+	results := GetCloseUSDBatch(context.Background(), requests, Options{Policy: PolicyExact})
+	if len(results) != len(requests) {
+		t.Fatalf("got %d results for %d requests", len(results), len(requests))
+	}
+	for i, res := range results[:24] {
+		if res.Err != nil || res.Response.ClosePrice != float64(23-i) {
+			t.Errorf("result %d = %v, %v, want %d", i, res.Response.ClosePrice, res.Err, 23-i)
+		}
+	}
+	if err := results[24].Err; !errors.Is(err, ErrUnknownSymbol) {
+		t.Errorf("last result error = %v, want ErrUnknownSymbol", err)
+	}
+}
+
+func TestGetCloseUSDBatchCancelled(t *testing.T) {
+	withSource(t, NewMemorySource())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	requests := make([]CloseRequest, 5)
+	for i := range requests {
+		requests[i] = CloseRequest{AssetClass: "crypto", InternalSymbol: fmt.Sprintf("S%d_USDT", i)}
+	}
+	for i, res := range GetCloseUSDBatch(ctx, requests, Options{}) {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("result %d error = %v, want context.Canceled", i, res.Err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// CandleDetail is a candle with its prices converted to the quote currency
+// at the candle's date. Volume and QuoteVolume are left as the data holds
+// them, in units of the symbol and of its quote currency. The open, high
+// and low of files without those columns are the close.
+type CandleDetail struct {
+	Date        string   `json:"date"`
+	Open        float64  `json:"open"`
+	High        float64  `json:"high"`
+	Low         float64  `json:"low"`
+	Close       float64  `json:"close"`
+	Volume      float64  `json:"volume"`
+	QuoteVolume float64  `json:"quoteVolume,omitempty"`
+	Metadata    Metadata `json:"metadata"`
+}
+
+type CandleResponse struct {
+	Currency string       `json:"currency"`
+	Candle   CandleDetail `json:"candle"`
+}
+
+type CandlesResponse struct {
+	Currency string         `json:"currency"`
+	Candles  []CandleDetail `json:"candles"`
+}
+
+// GetCandle returns the candle of internalSymbol that GetCloseUSD would
+// read its close from.
+func GetCandle(ctx context.Context, assetClass, internalSymbol string, date time.Time, opts Options) (CandleResponse, error) {
+	candle, err := closeAt(ctx, converter(), assetClass, internalSymbol, date, opts)
+	if err != nil {
+		return CandleResponse{}, err
+	}
+	return CandleResponse{Currency: candle.currency, Candle: candle.detail(0)}, nil
+}
+
+// GetCandles returns the candles of internalSymbol that GetCloseInBetween
+// would read its closes from.
+func GetCandles(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options) (CandlesResponse, error) {
+	if err := checkRange(start, end); err != nil {
+		return CandlesResponse{}, err
+	}
+
+	rng, err := closeRange(ctx, assetClass, internalSymbol, start, end, opts)
+	if err != nil {
+		return CandlesResponse{}, err
+	}
+
+	candles := make([]CandleDetail, len(rng.records))
+	for i := range rng.records {
+		candles[i] = rng.detail(i)
+	}
+	return CandlesResponse{Currency: rng.currency, Candles: candles}, nil
+}
+
+// detail converts the i-th candle at the rate its close was converted with.
+func (rng convertedRange) detail(i int) CandleDetail {
+	row, rate := rng.records[i], rng.conversions[i].Rate
+	open, high, low := row.prices()
+	return CandleDetail{
+		Date:        row.Date,
+		Open:        open * rate,
+		High:        high * rate,
+		Low:         low * rate,
+		Close:       rng.conversions[i].Amount,
+		Volume:      row.Volume,
+		QuoteVolume: row.QuoteVolume,
+		Metadata:    rng.metadata(i),
+	}
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetCandlesConvertsPrices(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_EUR", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Open: 0.40, High: 0.50, Low: 0.30, Close: 0.45, Volume: 1000, QuoteVolume: 450},
+		Record{Date: "2024-06-01T01:00:00Z", Open: 0.45, High: 0.60, Low: 0.40, Close: 0.50, Volume: 2000},
+	)
+	src.Add("forex", "EUR_USD", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 1.1},
+		Record{Date: "2024-06-01T01:00:00Z", Close: 1.2},
+	)
+	withSource(t, src)
+
+	resp, err := GetCandles(context.Background(), "crypto", "ADA_EUR", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-01T01:00:00Z"), Options{Policy: PolicyExact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Currency != "USD" || len(resp.Candles) != 2 {
+		t.Fatalf("response = %+v, want 2 USD candles", resp)
+	}
+
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	first, second := resp.Candles[0], resp.Candles[1]
+	if !near(first.Open, 0.44) || !near(first.High, 0.55) || !near(first.Low, 0.33) || !near(first.Close, 0.495) {
+		t.Errorf("first candle = %+v, want prices converted at 1.1", first)
+	}
+	if first.Volume != 1000 || first.QuoteVolume != 450 {
+		t.Errorf("first candle volumes = %v, %v, want 1000, 450", first.Volume, first.QuoteVolume)
+	}
+	if !near(second.High, 0.72) || second.Metadata.ConversionRate != 1.2 || second.QuoteVolume != 0 {
+		t.Errorf("second candle = %+v, want prices converted at 1.2", second)
+	}
+
+	single, err := GetCandle(context.Background(), "crypto", "ADA_EUR", time.Date(2024, 6, 1, 1, 10, 0, 0, time.UTC), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Candle.Date != "2024-06-01T01:00:00Z" || !near(single.Candle.Close, 0.6) {
+		t.Errorf("candle = %+v, want the 01:00 candle closing at 0.6", single.Candle)
+	}
+}
+
+func TestGetCandlesWithoutPriceColumns(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "crypto", "all", "ADA_USDT.csv")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("Date,Close\n2024-06-01T00:00:00Z,0.45\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	withSource(t, NewFileSource(cfg))
+
+	resp, err := GetCandles(context.Background(), "crypto", "ADA_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-01T00:00:00Z"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := resp.Candles[0]; c.Open != 0.45 || c.High != 0.45 || c.Low != 0.45 || c.Close != 0.45 {
+		t.Errorf("candle = %+v, want the close for the missing open, high and low", c)
+	}
+}
+
+func TestReadCSVQuoteVolume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ADA_USDT.csv")
+	body := "open_time,open,high,low,close,volume,quote_asset_volume\n1717200000000,1,2,0.5,1.5,10,15.5\n"
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := readCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Volume != 10 || records[0].QuoteVolume != 15.5 {
+		t.Errorf("records = %+v, want volume 10 and quote volume 15.5", records)
+	}
+}
@@ -0,0 +1,388 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Catalog is implemented by DataSources that can describe what they hold.
+type Catalog interface {
+	SymbolLister
+	// AssetClasses returns the sorted asset classes holding at least one
+	// symbol.
+	AssetClasses(ctx context.Context) ([]string, error)
+	// Coverage describes the candles held for symbol. It returns an error
+	// wrapping ErrNoData when the asset class has no such symbol.
+	Coverage(ctx context.Context, assetClass, symbol string) (Coverage, error)
+}
+
+// Coverage describes the candles held for one symbol of an asset class.
+type Coverage struct {
+	AssetClass string `json:"assetClass"`
+	Symbol     string `json:"symbol"`
+	// First and Last are the dates of the earliest and latest candles, at
+	// any interval.
+	First string `json:"first,omitempty"`
+	Last  string `json:"last,omitempty"`
+	// All reports whether the symbol has an IntervalAll file.
+	All bool `json:"all"`
+	// Days lists the days with dated files, in order.
+	Days []DayCoverage `json:"days"`
+}
+
+// DayCoverage lists the intervals a symbol has files for on one day.
+type DayCoverage struct {
+	Date      string   `json:"date"`
+	Intervals []string `json:"intervals"`
+}
+
+// catalogTTL is how long FileSource reuses a scan of its data root before
+// walking it again, so new files may take that long to be listed.
+const catalogTTL = time.Minute
+
+// fileCatalog is a scan of the files under a data root, by asset class and
+// symbol.
+type fileCatalog struct {
+	classes map[string]map[string]*symbolFiles
+	at      time.Time
+}
+
+// symbolFiles records which files exist for a symbol.
+type symbolFiles struct {
+	days map[string][]string // "2006-01-02" to intervals
+	all  bool
+}
+
+// catalogScan is a walk of the data root shared by the callers waiting
+// for it.
+type catalogScan struct {
+	done    chan struct{}
+	catalog *fileCatalog
+	err     error
+}
+
+// catalog returns a scan of the data root. Once the last scan is older than
+// catalogTTL a single scan is started in the background and the old one is
+// served until it completes; only the first scan is waited for.
+func (s *FileSource) catalog(ctx context.Context) (*fileCatalog, error) {
+	s.mu.Lock()
+	scanned := s.scanned
+	if scanned != nil && time.Since(scanned.at) < catalogTTL {
+		s.mu.Unlock()
+		return scanned, nil
+	}
+	scan := s.scanning
+	if scan == nil {
+		scan = &catalogScan{done: make(chan struct{})}
+		s.scanning = scan
+		go s.scan(scan)
+	}
+	s.mu.Unlock()
+
+	if scanned != nil {
+		return scanned, nil
+	}
+	select {
+	case <-scan.done:
+		return scan.catalog, scan.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// scan walks the data root for scan. It does not run on the context of the
+// request that started it, as other requests share its result.
+func (s *FileSource) scan(scan *catalogScan) {
+	scan.catalog, scan.err = scanDataRoot(context.Background(), s.cfg.DataRoot)
+
+	s.mu.Lock()
+	s.scanning = nil
+	if scan.err == nil {
+		s.scanned = scan.catalog
+	} else if s.scanned != nil {
+		log.Printf("Failed to rescan %s, listing the previous scan: %v", s.cfg.DataRoot, scan.err)
+	}
+	s.mu.Unlock()
+	close(scan.done)
+}
+
+// scanDataRoot walks root for files laid out as
+// <assetClass>/YYYY/MM/DD/<interval>/<SYMBOL>.csv or
+// <assetClass>/all/<SYMBOL>.csv. Other files are ignored.
+func scanDataRoot(ctx context.Context, root string) (*fileCatalog, error) {
+	c := &fileCatalog{classes: make(map[string]map[string]*symbolFiles), at: time.Now()}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".csv") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		symbol := strings.TrimSuffix(parts[len(parts)-1], filepath.Ext(path))
+
+		switch {
+		case len(parts) == 3 && parts[1] == IntervalAll:
+			c.symbol(parts[0], symbol).all = true
+		case len(parts) == 6:
+			day, err := time.Parse("2006/01/02", strings.Join(parts[1:4], "/"))
+			if err != nil {
+				return nil
+			}
+			files := c.symbol(parts[0], symbol)
+			date := day.Format("2006-01-02")
+			files.days[date] = append(files.days[date], parts[4])
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *fileCatalog) symbol(assetClass, symbol string) *symbolFiles {
+	symbols, ok := c.classes[assetClass]
+	if !ok {
+		symbols = make(map[string]*symbolFiles)
+		c.classes[assetClass] = symbols
+	}
+	files, ok := symbols[symbol]
+	if !ok {
+		files = &symbolFiles{days: make(map[string][]string)}
+		symbols[symbol] = files
+	}
+	return files
+}
+
+// AssetClasses lists the asset classes found under the data root.
+func (s *FileSource) AssetClasses(ctx context.Context) ([]string, error) {
+	c, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(c.classes), nil
+}
+
+// Symbols lists the symbols with files under the directory of assetClass.
+func (s *FileSource) Symbols(ctx context.Context, assetClass string) ([]string, error) {
+	c, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(c.classes[assetClass]), nil
+}
+
+// Coverage lists the days and intervals symbol has files for. First and
+// Last are read from the files of its earliest and latest days and from its
+// IntervalAll file.
+func (s *FileSource) Coverage(ctx context.Context, assetClass, symbol string) (Coverage, error) {
+	c, err := s.catalog(ctx)
+	if err != nil {
+		return Coverage{}, err
+	}
+	files, ok := c.classes[assetClass][symbol]
+	if !ok {
+		return Coverage{}, fmt.Errorf("%w: no files for %s/%s", ErrNoData, assetClass, symbol)
+	}
+
+	cov := Coverage{AssetClass: assetClass, Symbol: symbol, All: files.all, Days: []DayCoverage{}}
+	for _, date := range sortedKeys(files.days) {
+		intervals := append([]string{}, files.days[date]...)
+		sort.Strings(intervals)
+		cov.Days = append(cov.Days, DayCoverage{Date: date, Intervals: intervals})
+	}
+
+	var sets [][]Record
+	if len(cov.Days) > 0 {
+		for _, day := range []DayCoverage{cov.Days[0], cov.Days[len(cov.Days)-1]} {
+			date, _ := time.Parse("2006-01-02", day.Date)
+			for _, interval := range day.Intervals {
+				path, err := candlePath(s.cfg, assetClass, symbol, interval, date)
+				if err != nil {
+					return Coverage{}, err
+				}
+				records, err := readRecords(path)
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return Coverage{}, err
+				}
+				sets = append(sets, records)
+			}
+		}
+	}
+	if files.all {
+		records, err := s.readAll(ctx, assetClass, symbol)
+		if err != nil && !errors.Is(err, ErrNoData) {
+			return Coverage{}, err
+		}
+		sets = append(sets, records)
+	}
+	cov.First, cov.Last = recordSpan(sets...)
+	return cov, nil
+}
+
+// AssetClasses lists the asset classes holding at least one series.
+func (m *MemorySource) AssetClasses(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	classes := make(map[string]bool)
+	for key := range m.series {
+		classes[strings.SplitN(key, "/", 2)[0]] = true
+	}
+	return sortedKeys(classes), nil
+}
+
+// Coverage lists the days symbol has candles on, by interval.
+func (m *MemorySource) Coverage(ctx context.Context, assetClass, symbol string) (Coverage, error) {
+	if err := ctx.Err(); err != nil {
+		return Coverage{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := false
+	cov := Coverage{AssetClass: assetClass, Symbol: symbol, Days: []DayCoverage{}}
+	days := make(map[string]map[string]bool)
+	var sets [][]Record
+	for key, records := range m.series {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 || parts[0] != assetClass || parts[2] != symbol {
+			continue
+		}
+		found = true
+		sets = append(sets, records)
+
+		if parts[1] == IntervalAll {
+			cov.All = true
+			continue
+		}
+		for _, rec := range records {
+			date := rec.Time.Format("2006-01-02")
+			if days[date] == nil {
+				days[date] = make(map[string]bool)
+			}
+			days[date][parts[1]] = true
+		}
+	}
+	if !found {
+		return Coverage{}, fmt.Errorf("%w: %s/%s", ErrNoData, assetClass, symbol)
+	}
+
+	for _, date := range sortedKeys(days) {
+		cov.Days = append(cov.Days, DayCoverage{Date: date, Intervals: sortedKeys(days[date])})
+	}
+	cov.First, cov.Last = recordSpan(sets...)
+	return cov, nil
+}
+
+// recordSpan returns the dates of the earliest and latest records of the
+// date-sorted sets, or empty strings when they are all empty.
+func recordSpan(sets ...[]Record) (string, string) {
+	var first, last time.Time
+	for _, set := range sets {
+		if len(set) == 0 {
+			continue
+		}
+		if first.IsZero() || set[0].Time.Before(first) {
+			first = set[0].Time
+		}
+		if end := set[len(set)-1].Time; last.IsZero() || end.After(last) {
+			last = end
+		}
+	}
+	if first.IsZero() {
+		return "", ""
+	}
+	return first.Format(time.RFC3339), last.Format(time.RFC3339)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// catalogSource returns the package DataSource as a Catalog.
+func catalogSource() (Catalog, error) {
+	c, ok := source.(Catalog)
+	if !ok {
+		return nil, errors.New("the data source cannot list its contents")
+	}
+	return c, nil
+}
+
+// AssetClasses returns the asset classes of the package DataSource.
+func AssetClasses(ctx context.Context) ([]string, error) {
+	c, err := catalogSource()
+	if err != nil {
+		return nil, err
+	}
+	return c.AssetClasses(ctx)
+}
+
+// Symbols returns the symbols of assetClass in the package DataSource.
+func Symbols(ctx context.Context, assetClass string) ([]string, error) {
+	c, err := catalogSource()
+	if err != nil {
+		return nil, err
+	}
+	return c.Symbols(ctx, assetClass)
+}
+
+// SymbolCoverage describes the candles held for symbol in assetClass, or in
+// every asset class holding it when assetClass is empty. It returns an
+// error wrapping ErrNoData when none does.
+func SymbolCoverage(ctx context.Context, assetClass, symbol string) ([]Coverage, error) {
+	c, err := catalogSource()
+	if err != nil {
+		return nil, err
+	}
+
+	classes := []string{assetClass}
+	if assetClass == "" {
+		if classes, err = c.AssetClasses(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	coverage := []Coverage{}
+	for _, class := range classes {
+		cov, err := c.Coverage(ctx, class, symbol)
+		if errors.Is(err, ErrNoData) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		coverage = append(coverage, cov)
+	}
+	if len(coverage) == 0 {
+		return nil, fmt.Errorf("%w: unknown symbol %s", ErrNoData, symbol)
+	}
+	return coverage, nil
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestFileSourceCatalog(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv",
+		"2024-06-01T00:00:00Z,0,0,0,0.45,0\n"+
+			"2024-06-01T23:00:00Z,0,0,0,0.46,0\n")
+	writeCandles(t, root, "crypto/2024/06/01/1m/ADA_USDT.csv",
+		"2024-06-01T00:05:00Z,0,0,0,0.45,0\n")
+	writeCandles(t, root, "crypto/2024/06/03/1d/ADA_USDT.csv",
+		"2024-06-03T00:00:00Z,0,0,0,0.47,0\n")
+	writeCandles(t, root, "crypto/all/ADA_USDT.csv",
+		"2024-05-30T00:00:00Z,0,0,0,0.40,0\n")
+	writeCandles(t, root, "stocks/all/SAP_EUR.csv", "")
+	writeCandles(t, root, "stocks/notes/README.csv", "")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	src := NewFileSource(cfg)
+	ctx := context.Background()
+
+	classes, err := src.AssetClasses(ctx)
+	if err != nil || !reflect.DeepEqual(classes, []string{"crypto", "stocks"}) {
+		t.Errorf("AssetClasses = %v, %v", classes, err)
+	}
+	symbols, err := src.Symbols(ctx, "stocks")
+	if err != nil || !reflect.DeepEqual(symbols, []string{"SAP_EUR"}) {
+		t.Errorf("Symbols(stocks) = %v, %v", symbols, err)
+	}
+
+	cov, err := src.Coverage(ctx, "crypto", "ADA_USDT")
+	if err != nil {
+		t.Fatalf("Coverage returned error: %v", err)
+	}
+	want := Coverage{
+		AssetClass: "crypto",
+		Symbol:     "ADA_USDT",
+		First:      "2024-05-30T00:00:00Z",
+		Last:       "2024-06-03T00:00:00Z",
+		All:        true,
+		Days: []DayCoverage{
+			{Date: "2024-06-01", Intervals: []string{"1h", "1m"}},
+			{Date: "2024-06-03", Intervals: []string{"1d"}},
+		},
+	}
+	if !reflect.DeepEqual(cov, want) {
+		t.Errorf("Coverage = %+v, want %+v", cov, want)
+	}
+
+	if _, err := src.Coverage(ctx, "crypto", "SOL_USDT"); !errors.Is(err, ErrNoData) {
+		t.Errorf("Coverage of a missing symbol error = %v, want ErrNoData", err)
+	}
+}
+
+func TestFileSourceServesStaleCatalogWhileRescanning(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/all/ADA_USDT.csv", "2024-06-01T00:00:00Z,0,0,0,0.45,0\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	src := NewFileSource(cfg)
+	if _, err := src.Symbols(context.Background(), "crypto"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeCandles(t, root, "crypto/all/SOL_USDT.csv", "2024-06-01T00:00:00Z,0,0,0,160,0\n")
+	src.mu.Lock()
+	src.scanned.at = src.scanned.at.Add(-catalogTTL)
+	src.mu.Unlock()
+
+	// The request starting the rescan is served the previous scan, even
+	// when it is cancelled, and so are the others until the rescan ends.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	symbols, err := src.Symbols(ctx, "crypto")
+	if err != nil || !reflect.DeepEqual(symbols, []string{"ADA_USDT"}) {
+		t.Errorf("Symbols starting the rescan = %v, %v, want the previous scan", symbols, err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if symbols, err := src.Symbols(ctx, "crypto"); err != nil || len(symbols) == 0 {
+				t.Errorf("Symbols during the rescan = %v, %v", symbols, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	src.mu.Lock()
+	scan := src.scanning
+	src.mu.Unlock()
+	if scan != nil {
+		<-scan.done
+	}
+	symbols, err = src.Symbols(context.Background(), "crypto")
+	if err != nil || !reflect.DeepEqual(symbols, []string{"ADA_USDT", "SOL_USDT"}) {
+		t.Errorf("Symbols after the rescan = %v, %v", symbols, err)
+	}
+}
+
+func TestSymbolCoverageSearchesAssetClasses(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "BTC_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 68000})
+	src.Add("futures", "BTC_USDT", "1d", Record{Date: "2024-06-02T00:00:00Z", Close: 68100})
+	src.Add("crypto", "ETH_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 3800})
+	withSource(t, src)
+
+	coverage, err := SymbolCoverage(context.Background(), "", "BTC_USDT")
+	if err != nil {
+		t.Fatalf("SymbolCoverage returned error: %v", err)
+	}
+	if len(coverage) != 2 || coverage[0].AssetClass != "crypto" || coverage[1].AssetClass != "futures" {
+		t.Errorf("SymbolCoverage = %+v, want crypto then futures", coverage)
+	}
+
+	coverage, err = SymbolCoverage(context.Background(), "futures", "BTC_USDT")
+	if err != nil || len(coverage) != 1 || coverage[0].Days[0].Intervals[0] != "1d" {
+		t.Errorf("SymbolCoverage(futures) = %+v, %v", coverage, err)
+	}
+
+	if _, err := SymbolCoverage(context.Background(), "", "SOL_USDT"); !errors.Is(err, ErrNoData) {
+		t.Errorf("SymbolCoverage of a missing symbol error = %v, want ErrNoData", err)
+	}
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"pricing-api/pkg/cache"
+	"time"
+)
+
+// Config describes where the CSV data tree lives and how lookups walk it.
+type Config struct {
+	// DataRoot is the directory holding one subdirectory per asset class.
+	DataRoot string `json:"dataRoot"`
+	// ForexDir is the subdirectory of DataRoot holding the <BASE>_USD conversion files.
+	ForexDir string `json:"forexDir"`
+	// Intervals lists the candle intervals to probe, in order of priority.
+	Intervals []string `json:"intervals"`
+	// Sessions aligns candles resampled for an asset class, keyed by asset
+	// class. Asset classes without an entry are aligned to UTC midnight.
+	Sessions map[string]Session `json:"sessions"`
+	// CacheMaxBytes bounds the memory used by parsed CSV files kept in memory.
+	CacheMaxBytes int64 `json:"cacheMaxBytes"`
+	// CacheMaxEntries bounds the number of parsed CSV files kept in memory.
+	CacheMaxEntries int `json:"cacheMaxEntries"`
+	// BatchWorkers bounds the lookups of a batch run at the same time.
+	BatchWorkers int `json:"batchWorkers"`
+	// StaleAfter is how far, as a Go duration, the price or conversion rate
+	// of a portfolio position may lie from the valuation date before the
+	// position is flagged as stale.
+	StaleAfter string `json:"staleAfter"`
+	// FXStaleness is how far, as a Go duration, the candle a conversion rate
+	// is read from may lie before the price it converts. It should cover
+	// the weekends and holidays of the forex series.
+	FXStaleness string `json:"fxStaleness"`
+	// RegistryPath is an optional JSON Registry file describing symbols and
+	// quote assets. DefaultRegistry is used when it is empty.
+	RegistryPath string `json:"registryPath"`
+}
+
+// DefaultConfig returns the configuration used when nothing else is provided.
+func DefaultConfig() Config {
+	return Config{
+		DataRoot:        "data",
+		ForexDir:        "forex",
+		Intervals:       []string{"1m", "2m", "5m", "15m", "1h", "1w", "1d"},
+		CacheMaxBytes:   512 << 20,
+		CacheMaxEntries: 4096,
+		BatchWorkers:    8,
+		StaleAfter:      "24h",
+		FXStaleness:     "96h",
+	}
+}
+
+// LoadConfig reads a JSON config file. Fields missing from the file keep
+// their DefaultConfig values.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate reports whether the configuration can be used for lookups.
+func (c Config) Validate() error {
+	if c.DataRoot == "" {
+		return errors.New("data root must not be empty")
+	}
+	if c.ForexDir == "" {
+		return errors.New("forex directory must not be empty")
+	}
+	if len(c.Intervals) == 0 {
+		return errors.New("at least one interval is required")
+	}
+	if c.BatchWorkers < 0 {
+		return errors.New("batch workers must not be negative")
+	}
+	if _, err := c.staleAfter(); err != nil {
+		return err
+	}
+	if _, err := c.fxStaleness(); err != nil {
+		return err
+	}
+	for assetClass, session := range c.Sessions {
+		if _, _, err := session.anchor(); err != nil {
+			return fmt.Errorf("session for %s: %v", assetClass, err)
+		}
+	}
+	return nil
+}
+
+// staleAfter parses StaleAfter. An empty value disables the stale flag.
+func (c Config) staleAfter() (time.Duration, error) {
+	if c.StaleAfter == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.StaleAfter)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid stale after %q", c.StaleAfter)
+	}
+	return d, nil
+}
+
+// fxStaleness parses FXStaleness. An empty value selects the default
+// lookup window.
+func (c Config) fxStaleness() (time.Duration, error) {
+	if c.FXStaleness == "" {
+		return defaultLookupWindow, nil
+	}
+	d, err := time.ParseDuration(c.FXStaleness)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid fx staleness %q", c.FXStaleness)
+	}
+	return d, nil
+}
+
+// config is the configuration used by the package level lookup functions.
+var config = DefaultConfig()
+
+// Configure replaces the configuration used by GetCloseUSD, GetCloseInBetween
+// and the other package level lookups, resets the DataSource to a
+// FileSource over cfg, loads the symbol registry and empties the file cache.
+// It should be called once at startup.
+func Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid search config: %v", err)
+	}
+
+	reg := DefaultRegistry()
+	if cfg.RegistryPath != "" {
+		var err error
+		reg, err = LoadRegistry(cfg.RegistryPath)
+		if err != nil {
+			return fmt.Errorf("invalid symbol registry: %v", err)
+		}
+	}
+
+	config = cfg
+	registry = reg
+	source = NewFileSource(cfg)
+	files = cache.New[[]Record](cfg.CacheMaxBytes, cfg.CacheMaxEntries)
+	return nil
+}
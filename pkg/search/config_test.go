@@ -0,0 +1,25 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"dataRoot": "/srv/pricing"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if cfg.DataRoot != "/srv/pricing" {
+		t.Errorf("DataRoot = %q, want /srv/pricing", cfg.DataRoot)
+	}
+	if cfg.ForexDir != "forex" || len(cfg.Intervals) != len(DefaultConfig().Intervals) {
+		t.Errorf("defaults were not kept: %+v", cfg)
+	}
+}
@@ -3,60 +3,207 @@ package search
 import (
 	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"pricing-api/pkg/cache"
+	"sort"
 	"strconv"
+	"time"
+	"unsafe"
 
 	"github.com/blevesearch/bleve"
 )
 
 type Record struct {
-	Date   string
+	Date string
+	// Time is Date parsed, filled in when records are read.
+	Time   time.Time `json:"-"`
 	Open   float64
 	High   float64
 	Low    float64
 	Close  float64
-	Volume int64
+	Volume float64
+	// QuoteVolume is the volume in the symbol's quote currency, for files
+	// that carry it.
+	QuoteVolume float64
+	// missing lists the columns the record had no value for, so that a
+	// zero Open, High or Low can be told from an absent one.
+	missing columnSet
+	// fromAll reports that a FileSource read the record from the
+	// IntervalAll file to fill a day without a file at the interval asked.
+	fromAll bool
 }
 
-func readCSV(filePath string) ([]map[string]string, error) {
-	file, err := os.Open(filePath)
+// columnSet is a set of optional price columns of a Record.
+type columnSet uint8
+
+const (
+	columnOpen columnSet = 1 << iota
+	columnHigh
+	columnLow
+)
+
+// prices returns the open, high and low of a candle, falling back to its
+// close for files without those columns.
+func (r Record) prices() (open, high, low float64) {
+	open, high, low = r.Open, r.High, r.Low
+	if r.missing&columnOpen != 0 {
+		open = r.Close
+	}
+	if r.missing&columnHigh != 0 {
+		high = r.Close
+	}
+	if r.missing&columnLow != 0 {
+		low = r.Close
+	}
+	return open, high, low
+}
+
+// prepareRecords parses the Date of each record into Time, normalizing it
+// to RFC 3339 in UTC, drops records whose Date cannot be parsed and sorts
+// the rest by Time, keeping the first of several records with the same
+// Time.
+func prepareRecords(records []Record) []Record {
+	out := records[:0]
+	for _, rec := range records {
+		t, err := parseTimestamp(rec.Date)
+		if err != nil {
+			continue
+		}
+		rec.Time = t
+		rec.Date = t.Format(time.RFC3339)
+		out = append(out, rec)
+	}
+	sortRecords(out)
+	return dedupRecords(out)
+}
+
+func sortRecords(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+}
+
+// dedupRecords drops, in place, the records of date-sorted records sharing
+// the Time of the one before them.
+func dedupRecords(records []Record) []Record {
+	if len(records) == 0 {
+		return records
+	}
+	out := records[:1]
+	for _, rec := range records[1:] {
+		if !rec.Time.Equal(out[len(out)-1].Time) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// files caches the parsed contents of the CSV files read by readRecords.
+var files = cache.New[[]Record](DefaultConfig().CacheMaxBytes, DefaultConfig().CacheMaxEntries)
+
+// recordSize estimates the memory held by one parsed Record.
+const recordSize = int64(unsafe.Sizeof(Record{}))
+
+// readRecords returns the date-sorted records of the CSV file at path,
+// parsing it only when it is not cached or has changed on disk. The
+// returned slice is shared and must not be modified. A missing file is
+// reported with an error satisfying errors.Is(err, fs.ErrNotExist).
+func readRecords(path string) ([]Record, error) {
+	return files.Get(path, func(path string) ([]Record, int64, error) {
+		records, err := readCSV(path)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		size := int64(len(records)) * recordSize
+		for _, rec := range records {
+			size += int64(len(rec.Date))
+		}
+		return records, size, nil
+	})
+}
+
+// CacheStats reports the activity of the parsed file cache.
+func CacheStats() cache.Stats {
+	return files.Stats()
+}
+
+// readCSV reads the candles of a CSV file into date-sorted Records, mapping
+// its header row through headerAliases. Of several rows with the same date
+// the first in the file is kept. Rows that cannot be read are
+// reported as *ParseError values joined into the returned error.
+func readCSV(filePath string) ([]Record, error) {
+	records, _, err := readCSVRows(filePath)
 	if err != nil {
 		return nil, err
 	}
+	sortRecords(records)
+	return dedupRecords(records), nil
+}
+
+// readCSVRows is readCSV without the sorting: records are returned in file
+// order along with the line each was read from.
+func readCSVRows(filePath string) ([]Record, []int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer file.Close()
 
 	reader := csv.NewReader(bufio.NewReader(file))
-	var data []map[string]string
-	var headers []string
-	firstLine := true
+	reader.FieldsPerRecord = -1 // short rows are reported per column by the schema
+	reader.ReuseRecord = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	s, err := newSchema(filePath, append([]string(nil), headers...))
+	if err != nil {
+		return nil, nil, err
+	}
 
+	var records []Record
+	var lines []int
+	var errs []error
+	skipped := 0
 	for {
-		record, err := reader.Read()
+		row, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err // Handle non-EOF errors
+			return nil, nil, fmt.Errorf("failed to read %s: %v", filePath, err) // csv errors carry their line
 		}
 
-		if firstLine {
-			headers = record
-			firstLine = false
+		line, _ := reader.FieldPos(0)
+		rec, rowErrs := s.parseRow(row, line)
+		if len(rowErrs) > 0 {
+			for _, err := range rowErrs {
+				if len(errs) < maxParseErrors {
+					errs = append(errs, err)
+				} else {
+					skipped++
+				}
+			}
 			continue
 		}
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
 
-		row := make(map[string]string)
-		for i, value := range record {
-			if i < len(headers) { // Check to prevent index out of range errors
-				row[headers[i]] = value
-			}
+	if len(errs) > 0 {
+		if skipped > 0 {
+			errs = append(errs, fmt.Errorf("%s: %d more errors", filePath, skipped))
 		}
-		data = append(data, row)
+		return nil, nil, errors.Join(errs...)
 	}
-	return data, nil
+	return records, lines, nil
 }
 
 func queryIndex(index bleve.Index, queryStr string) ([]map[string]string, error) {
@@ -131,7 +278,7 @@ func indexCSV(filePath string, index bleve.Index) error {
 			High:   parseFloat(data["High"]),
 			Low:    parseFloat(data["Low"]),
 			Close:  parseFloat(data["Close"]),
-			Volume: parseInt(data["Volume"]),
+			Volume: parseFloat(data["Volume"]),
 		}
 
 		// Indexing the record using Bleve
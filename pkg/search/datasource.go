@@ -0,0 +1,284 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IntervalAll names the per-symbol file under <assetClass>/all that holds
+// candles of every day, used when no dated file exists.
+const IntervalAll = "all"
+
+// ErrNoData is returned by a DataSource that holds no candles for a request.
+var ErrNoData = errors.New("no data found")
+
+// ErrUnknownSymbol is returned, along with ErrNoData, when the DataSource
+// is a Catalog that does not list the symbol at all.
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// ErrInvalidRequest is wrapped by the errors caused by the arguments of a
+// lookup rather than by the data.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrNoMatch is returned when candles exist but none satisfies the lookup
+// policy and maximum staleness of a request.
+var ErrNoMatch = errors.New("no candle matches the lookup")
+
+// DataSource provides the candles the price lookups are computed from.
+type DataSource interface {
+	// Candles returns the candles of symbol at the given interval whose
+	// date lies within [from, to], sorted by date. It returns an error
+	// wrapping ErrNoData when the source has nothing for the request.
+	Candles(ctx context.Context, assetClass, symbol, interval string, from, to time.Time) ([]Record, error)
+}
+
+// SymbolLister is implemented by DataSources that can enumerate the symbols
+// they hold for an asset class.
+type SymbolLister interface {
+	// Symbols returns the sorted, distinct symbols of assetClass at any
+	// interval.
+	Symbols(ctx context.Context, assetClass string) ([]string, error)
+}
+
+// FileSource reads candles from the CSV tree described by a Config:
+// <DataRoot>/<assetClass>/YYYY/MM/DD/<interval>/<SYMBOL>.csv, with
+// <DataRoot>/<assetClass>/all/<SYMBOL>.csv served as IntervalAll.
+type FileSource struct {
+	cfg Config
+
+	mu       sync.Mutex
+	scanned  *fileCatalog
+	scanning *catalogScan // the scan in flight, if any
+}
+
+func NewFileSource(cfg Config) *FileSource {
+	return &FileSource{cfg: cfg}
+}
+
+// Candles stitches together the dated files of every day in [from, to].
+// Days without a file at interval are filled from the IntervalAll file,
+// with the records marked as such; if no day has one at all, ErrNoData is
+// returned so callers can try another interval.
+func (s *FileSource) Candles(ctx context.Context, assetClass, symbol, interval string, from, to time.Time) ([]Record, error) {
+	if interval == IntervalAll {
+		records, err := s.readAll(ctx, assetClass, symbol)
+		if err != nil {
+			return nil, err
+		}
+		return nonEmpty(filterRecords(records, from, to), assetClass, symbol, interval, from, to)
+	}
+
+	var sets [][]Record
+	var gaps []time.Time
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path, err := candlePath(s.cfg, assetClass, symbol, interval, day)
+		if err != nil {
+			return nil, err
+		}
+		records, err := readRecords(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			gaps = append(gaps, day)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, records)
+	}
+
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("%w: no %s files for %s/%s", ErrNoData, interval, assetClass, symbol)
+	}
+
+	if len(gaps) > 0 {
+		all, err := s.readAll(ctx, assetClass, symbol)
+		if err != nil && !errors.Is(err, ErrNoData) {
+			return nil, err
+		}
+		for _, day := range gaps {
+			dayStart, dayEnd := dayBounds(day)
+			// Copied, as the records of the file are cached.
+			filled := append([]Record(nil), filterRecords(all, dayStart, dayEnd)...)
+			for i := range filled {
+				filled[i].fromAll = true
+			}
+			sets = append(sets, filled)
+		}
+	}
+
+	return nonEmpty(filterRecords(mergeRecords(sets...), from, to), assetClass, symbol, interval, from, to)
+}
+
+// readAll reads every candle of the IntervalAll file of symbol.
+func (s *FileSource) readAll(ctx context.Context, assetClass, symbol string) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := candlePath(s.cfg, assetClass, symbol, IntervalAll, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	records, err := readRecords(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoData, path)
+	}
+	return records, err
+}
+
+// nonEmpty returns records, or an ErrNoData error when there are none.
+func nonEmpty(records []Record, assetClass, symbol, interval string, from, to time.Time) ([]Record, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s/%s between %s and %s", ErrNoData, assetClass, interval, symbol, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return records, nil
+}
+
+// candlePath returns the file holding symbol's candles at interval for the day of date.
+// The asset class, symbol and interval must each be a single path element,
+// so that a lookup cannot reach the files of another asset class or leave
+// DataRoot.
+func candlePath(cfg Config, assetClass, symbol, interval string, date time.Time) (string, error) {
+	for _, name := range []string{assetClass, symbol, interval} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", fmt.Errorf("%w: invalid path element %q", ErrInvalidRequest, name)
+		}
+	}
+
+	fileName := fmt.Sprintf("%s.csv", symbol)
+	path := filepath.Join(cfg.DataRoot, assetClass, date.Format("2006"), date.Format("01"), date.Format("02"), interval, fileName)
+	if interval == IntervalAll {
+		path = filepath.Join(cfg.DataRoot, assetClass, IntervalAll, fileName)
+	}
+	if rel, err := filepath.Rel(cfg.DataRoot, path); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s/%s is outside the data root", ErrInvalidRequest, assetClass, symbol)
+	}
+	return path, nil
+}
+
+// MemorySource is a DataSource holding candles in memory, mainly for tests.
+type MemorySource struct {
+	mu     sync.RWMutex
+	series map[string][]Record
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{series: make(map[string][]Record)}
+}
+
+// Add stores records for symbol at the given interval. Records whose Date
+// is not RFC 3339 are ignored.
+func (m *MemorySource) Add(assetClass, symbol, interval string, records ...Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey(assetClass, symbol, interval)
+	added := prepareRecords(append([]Record{}, records...))
+	m.series[key] = mergeRecords(added, m.series[key])
+}
+
+func (m *MemorySource) Candles(ctx context.Context, assetClass, symbol, interval string, from, to time.Time) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	records := filterRecords(m.series[memoryKey(assetClass, symbol, interval)], from, to)
+	m.mu.RUnlock()
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s/%s", ErrNoData, assetClass, interval, symbol)
+	}
+	return records, nil
+}
+
+func (m *MemorySource) Symbols(ctx context.Context, assetClass string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var symbols []string
+	for key := range m.series {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) == 3 && parts[0] == assetClass && !seen[parts[2]] {
+			seen[parts[2]] = true
+			symbols = append(symbols, parts[2])
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func memoryKey(assetClass, symbol, interval string) string {
+	return assetClass + "/" + interval + "/" + symbol
+}
+
+// mergeRecords combines date-sorted sets of records into one sorted stream.
+// When several records share a timestamp the one from the earliest set is
+// kept. Each set must already hold one record per timestamp, as readCSV and
+// prepareRecords return them.
+func mergeRecords(sets ...[]Record) []Record {
+	var nonEmpty [][]Record
+	total := 0
+	for _, set := range sets {
+		if len(set) > 0 {
+			nonEmpty = append(nonEmpty, set)
+			total += len(set)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+
+	seen := make(map[int64]bool, total)
+	merged := make([]Record, 0, total)
+	for _, set := range nonEmpty {
+		for _, rec := range set {
+			key := rec.Time.UnixNano()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, rec)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	return merged
+}
+
+// filterRecords returns the part of date-sorted records dated within
+// [from, to]. The result shares its backing array with records, which may
+// be cached, so it must not be modified.
+func filterRecords(records []Record, from, to time.Time) []Record {
+	lo := sort.Search(len(records), func(i int) bool { return !records[i].Time.Before(from) })
+	hi := sort.Search(len(records), func(i int) bool { return records[i].Time.After(to) })
+	if lo >= hi {
+		return nil
+	}
+	return records[lo:hi:hi]
+}
+
+// source is the DataSource used by the package level lookup functions.
+var source DataSource = NewFileSource(config)
+
+// SetDataSource replaces the DataSource used by GetCloseUSD and
+// GetCloseInBetween. Configure resets it to a FileSource, so call it after.
+func SetDataSource(src DataSource) {
+	source = src
+}
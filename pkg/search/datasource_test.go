@@ -0,0 +1,204 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeCandles writes a CSV file under root, creating its directories.
+func writeCandles(t *testing.T, root, rel, body string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("Date,Open,High,Low,Close,Volume\n"+body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSourceUsesConfiguredRoot(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv",
+		"2024-06-01T00:00:00Z,0.44,0.46,0.43,0.45,100\n"+
+			"2024-06-01T01:00:00Z,0.45,0.47,0.44,0.46,120\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	src := NewFileSource(cfg)
+	from, to := dayBounds(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	records, err := src.Candles(context.Background(), "crypto", "ADA_USDT", "1h", from, to)
+	if err != nil {
+		t.Fatalf("Candles returned error: %v", err)
+	}
+	if len(records) != 2 || records[1].Close != 0.46 {
+		t.Errorf("unexpected records: %+v", records)
+	}
+
+	if _, err := src.Candles(context.Background(), "crypto", "ADA_USDT", "1d", from, to); !errors.Is(err, ErrNoData) {
+		t.Errorf("expected ErrNoData for a missing interval, got %v", err)
+	}
+}
+
+func TestFileSourceStitchesDays(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv",
+		"2024-06-01T22:00:00Z,0,0,0,0.41,0\n"+
+			"2024-06-01T23:00:00Z,0,0,0,0.42,0\n")
+	writeCandles(t, root, "crypto/2024/06/03/1h/ADA_USDT.csv",
+		"2024-06-03T00:00:00Z,0,0,0,0.45,0\n"+
+			"2024-06-03T01:00:00Z,0,0,0,0.46,0\n")
+	// The all file fills 2024-06-02 and repeats a candle already in a day file.
+	writeCandles(t, root, "crypto/all/ADA_USDT.csv",
+		"2024-06-03T00:00:00Z,0,0,0,9.99,0\n"+
+			"2024-06-02T12:00:00Z,0,0,0,0.44,0\n"+
+			"2024-06-02T00:00:00Z,0,0,0,0.43,0\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	from, _ := time.Parse(time.RFC3339, "2024-06-01T23:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2024-06-03T00:30:00Z")
+
+	records, err := NewFileSource(cfg).Candles(context.Background(), "crypto", "ADA_USDT", "1h", from, to)
+	if err != nil {
+		t.Fatalf("Candles returned error: %v", err)
+	}
+
+	want := []float64{0.42, 0.43, 0.44, 0.45}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, rec := range records {
+		if rec.Close != want[i] {
+			t.Errorf("record %d (%s) Close = %v, want %v", i, rec.Date, rec.Close, want[i])
+		}
+	}
+}
+
+func TestGapDaysAreReportedAsAll(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1m/ADA_USDT.csv", "2024-06-01T23:59:00Z,0,0,0,0.41,0\n")
+	writeCandles(t, root, "crypto/2024/06/03/1m/ADA_USDT.csv", "2024-06-03T00:00:00Z,0,0,0,0.43,0\n")
+	writeCandles(t, root, "crypto/all/ADA_USDT.csv", "2024-06-02T00:00:00Z,0,0,0,0.42,0\n")
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	withConfig(t, cfg)
+
+	start, end := mustParse("2024-06-01T23:59:00Z"), mustParse("2024-06-03T00:00:00Z")
+	result, err := GetCloseInBetween(context.Background(), "crypto", "ADA_USDT", start, end, Options{Candle: "1m"})
+	if err != nil {
+		t.Fatalf("GetCloseInBetween returned error: %v", err)
+	}
+	var candles []string
+	for _, detail := range result.ClosePrices {
+		candles = append(candles, detail.Metadata.Candle)
+	}
+	if want := []string{"1m", IntervalAll, "1m"}; !reflect.DeepEqual(candles, want) {
+		t.Errorf("candles = %v, want %v", candles, want)
+	}
+
+	// A strict lookup takes the 1m candles only.
+	result, err = GetCloseInBetween(context.Background(), "crypto", "ADA_USDT", start, end, Options{Candle: "1m", Strict: true})
+	if err != nil {
+		t.Fatalf("strict GetCloseInBetween returned error: %v", err)
+	}
+	var dates []string
+	for _, detail := range result.ClosePrices {
+		dates = append(dates, detail.Date)
+	}
+	if want := []string{"2024-06-01T23:59:00Z", "2024-06-03T00:00:00Z"}; !reflect.DeepEqual(dates, want) {
+		t.Errorf("strict dates = %v, want %v", dates, want)
+	}
+}
+
+func TestFileSourceDropsDuplicateRows(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv",
+		"2024-06-01T00:00:00Z,0,0,0,0.45,0\n"+
+			"2024-06-01T01:00:00Z,0,0,0,0.46,0\n"+
+			"2024-06-01T00:00:00Z,0,0,0,9.99,0\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	from, to := dayBounds(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	records, err := NewFileSource(cfg).Candles(context.Background(), "crypto", "ADA_USDT", "1h", from, to)
+	if err != nil {
+		t.Fatalf("Candles returned error: %v", err)
+	}
+
+	want := []float64{0.45, 0.46}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, rec := range records {
+		if rec.Close != want[i] {
+			t.Errorf("record %d (%s) Close = %v, want %v", i, rec.Date, rec.Close, want[i])
+		}
+	}
+}
+
+func TestFileSourceParsesCorruptFilesOnce(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv", "2024-06-01T00:00:00Z,0,0,0,abc,0\n")
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	withConfig(t, cfg)
+
+	from, to := dayBounds(mustParse("2024-06-01T00:00:00Z"))
+	for i := 0; i < 2; i++ {
+		var perr *ParseError
+		if _, err := source.Candles(context.Background(), "crypto", "ADA_USDT", "1h", from, to); !errors.As(err, &perr) || perr.Line != 2 {
+			t.Fatalf("Candles = %v, want the parse error of line 2", err)
+		}
+	}
+	if stats := CacheStats(); stats.Misses != 1 || stats.Hits != 1 {
+		t.Errorf("cache stats = %+v, want the file parsed once", stats)
+	}
+}
+
+func TestFileSourceStaysWithinAssetClass(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "equities/all/SECRET_USD.csv", "2024-06-01T00:00:00Z,0,0,0,123,0\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	from, to := dayBounds(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	for _, symbol := range []string{"../../equities/all/SECRET_USD", "../equities", ".."} {
+		for _, interval := range []string{"1h", IntervalAll} {
+			_, err := NewFileSource(cfg).Candles(context.Background(), "crypto", symbol, interval, from, to)
+			if !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("Candles(%q, %s) error = %v, want ErrInvalidRequest", symbol, interval, err)
+			}
+		}
+	}
+}
+
+func TestFileSourceSymbols(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "forex/2024/06/01/1h/EUR_USD.csv", "")
+	writeCandles(t, root, "forex/2024/06/02/1d/EUR_USD.csv", "")
+	writeCandles(t, root, "forex/all/TRY_EUR.csv", "")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	src := NewFileSource(cfg)
+
+	symbols, err := src.Symbols(context.Background(), "forex")
+	if err != nil {
+		t.Fatalf("Symbols returned error: %v", err)
+	}
+	if want := []string{"EUR_USD", "TRY_EUR"}; !reflect.DeepEqual(symbols, want) {
+		t.Errorf("Symbols = %v, want %v", symbols, want)
+	}
+
+	if symbols, err := src.Symbols(context.Background(), "stocks"); err != nil || len(symbols) != 0 {
+		t.Errorf("Symbols of a missing asset class = %v, %v", symbols, err)
+	}
+}
@@ -0,0 +1,351 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxFXLegs caps how many pairs a triangulated conversion may chain.
+const maxFXLegs = 3
+
+// maxFXPaths caps how many equally short paths are priced when choosing the
+// freshest one.
+const maxFXPaths = 8
+
+// Conversion is the result of converting an amount between currencies.
+type Conversion struct {
+	// Amount is the converted amount.
+	Amount float64
+	// Rate is the number of units of the target currency per unit of the
+	// source currency.
+	Rate float64
+	// RateDate is the date of the forex candle the rate was read from. For
+	// a triangulated rate it is the date of the stalest leg.
+	RateDate time.Time
+	// Legs are the pairs the rate was built from, in conversion order. It
+	// is empty when both currencies are the same.
+	Legs []Leg
+}
+
+// Leg is one series used by a Conversion: a forex pair, or the price of a
+// crypto quote asset.
+type Leg struct {
+	// AssetClass is the directory of the series, Config.ForexDir for forex
+	// pairs.
+	AssetClass string
+	// Pair is the series the rate was read from, e.g. "EUR_USD".
+	Pair string
+	// Rate is the number of units of the leg's target currency per unit of
+	// its source currency, inverted from Pair when the series is quoted
+	// the other way round.
+	Rate float64
+	// RateDate is the date of the candle the rate was read from.
+	RateDate time.Time
+}
+
+// FXConverter converts amounts between currencies using the <FROM>_<TO>
+// forex series found under Config.ForexDir of a DataSource, inverting
+// <TO>_<FROM> series when needed. When neither exists and the DataSource
+// is a SymbolLister, the rate is triangulated through the other pairs it
+// holds. Crypto assets of the Registry without forex series are priced from
+// their own series in the asset tree.
+//
+// An FXConverter keeps the last days of series it has read in memory, so
+// that converting the prices of a range or a batch reads each series once.
+// It does not see data added after it read a day, so it should be created
+// for a lookup or a batch rather than kept.
+type FXConverter struct {
+	source   DataSource
+	cfg      Config
+	registry Registry
+	rates    *rateMemo
+}
+
+func NewFXConverter(src DataSource, cfg Config, reg Registry) *FXConverter {
+	return &FXConverter{source: src, cfg: cfg, registry: reg, rates: newRateMemo()}
+}
+
+// Convert converts amount from one currency to another at the rates known
+// at at: each series is read as of at, from its last candle at or before
+// at and no older than Config.FXStaleness. The lookup policy of the price
+// being converted does not apply, so that a rate never comes from after
+// the price.
+func (c *FXConverter) Convert(ctx context.Context, amount float64, from, to string, at time.Time) (Conversion, error) {
+	return c.convert(ctx, amount, from, to, at, 0)
+}
+
+// convert is Convert for a lookup allowing prices up to maxStaleness from
+// its date. The price of a crypto quote asset is a price like any other,
+// so when maxStaleness is set it bounds the age of that leg as well as
+// Config.FXStaleness does; forex legs are only bound by the latter.
+func (c *FXConverter) convert(ctx context.Context, amount float64, from, to string, at time.Time, maxStaleness time.Duration) (Conversion, error) {
+	legs, err := c.legs(ctx, from, to, at, maxStaleness, nil)
+	if err != nil {
+		return Conversion{}, err
+	}
+	if len(legs) == 0 {
+		return Conversion{Amount: amount, Rate: 1, RateDate: at}, nil
+	}
+	return newConversion(amount, at, legs), nil
+}
+
+// legs returns the series converting from one currency to another: a
+// forex pair, a triangulated path of pairs or, for a crypto asset, its own
+// price series followed by the legs from the currency that series is
+// quoted in. The price of a crypto asset is no older than maxStaleness,
+// when set. priced holds the crypto assets already priced on the way, so
+// that assets quoted in each other do not recurse forever.
+func (c *FXConverter) legs(ctx context.Context, from, to string, at time.Time, maxStaleness time.Duration, priced map[string]bool) ([]Leg, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	leg, err := c.leg(ctx, from, to, at)
+	if err == nil {
+		return []Leg{leg}, nil
+	}
+	if !errors.Is(err, ErrNoData) {
+		return nil, err
+	}
+
+	legs, triErr := c.triangulate(ctx, from, to, at)
+	if triErr == nil {
+		return legs, nil
+	}
+	if !errors.Is(triErr, ErrNoData) {
+		return nil, triErr
+	}
+
+	asset := c.registry.Asset(from)
+	if asset.Kind != AssetCrypto || priced[from] {
+		return nil, err
+	}
+	assetClass, symbol := asset.series(from)
+	price, priceDate, assetErr := c.seriesRate(ctx, assetClass, symbol, at, maxStaleness)
+	if errors.Is(assetErr, ErrNoData) {
+		return nil, err
+	}
+	if assetErr != nil {
+		return nil, assetErr
+	}
+
+	if priced == nil {
+		priced = make(map[string]bool)
+	}
+	priced[from] = true
+	rest, err := c.legs(ctx, c.registry.quoteCurrency(symbol), to, at, maxStaleness, priced)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert the %s price of %s: %w", symbol, from, err)
+	}
+	return append([]Leg{{AssetClass: assetClass, Pair: symbol, Rate: price, RateDate: priceDate}}, rest...), nil
+}
+
+// newConversion multiplies the rates of legs and dates the result by the
+// leg furthest from at.
+func newConversion(amount float64, at time.Time, legs []Leg) Conversion {
+	conv := Conversion{Rate: 1, Legs: legs}
+	for _, leg := range legs {
+		conv.Rate *= leg.Rate
+		if conv.RateDate.IsZero() || leg.RateDate.Sub(at).Abs() > conv.RateDate.Sub(at).Abs() {
+			conv.RateDate = leg.RateDate
+		}
+	}
+	conv.Amount = amount * conv.Rate
+	return conv
+}
+
+// leg returns the rate from one currency to another at at, read from the
+// <from>_<to> series or, failing that, inverted from the <to>_<from>
+// series.
+func (c *FXConverter) leg(ctx context.Context, from, to string, at time.Time) (Leg, error) {
+	pair := from + "_" + to
+	rate, rateDate, err := c.seriesRate(ctx, c.cfg.ForexDir, pair, at, 0)
+	if !errors.Is(err, ErrNoData) {
+		return Leg{AssetClass: c.cfg.ForexDir, Pair: pair, Rate: rate, RateDate: rateDate}, err
+	}
+
+	inverted := to + "_" + from
+	inverse, rateDate, invErr := c.seriesRate(ctx, c.cfg.ForexDir, inverted, at, 0)
+	if errors.Is(invErr, ErrNoData) {
+		return Leg{}, fmt.Errorf("%w: no %s or %s rate as of %s", ErrNoData, pair, inverted, at.Format(time.RFC3339))
+	}
+	if invErr != nil {
+		return Leg{}, invErr
+	}
+	if inverse == 0 {
+		return Leg{}, fmt.Errorf("cannot invert a zero %s rate at %s", inverted, rateDate.Format(time.RFC3339))
+	}
+	return Leg{AssetClass: c.cfg.ForexDir, Pair: inverted, Rate: 1 / inverse, RateDate: rateDate}, nil
+}
+
+// seriesRate returns the close of a series as of at, trying the configured
+// intervals in order of priority before IntervalAll. An interval without a
+// candle in the staleness window gives way to the next one. The window is
+// Config.FXStaleness, narrowed to maxStaleness when that is set and shorter.
+func (c *FXConverter) seriesRate(ctx context.Context, assetClass, symbol string, at time.Time, maxStaleness time.Duration) (float64, time.Time, error) {
+	window, err := c.cfg.fxStaleness()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	staleness := window
+	if maxStaleness > 0 && maxStaleness < staleness {
+		staleness = maxStaleness
+	}
+
+	var noMatch error
+	intervals := append(append([]string{}, c.cfg.Intervals...), IntervalAll)
+	for _, interval := range intervals {
+		day, err := c.rates.day(ctx, c.source, rateKey{assetClass, symbol, interval, dayOf(at)}, window)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+
+		rec, err := day.asOf(at, staleness)
+		if errors.Is(err, ErrNoData) {
+			continue
+		}
+		if errors.Is(err, ErrNoMatch) {
+			noMatch = fmt.Errorf("no %s rate as of %s: %w", symbol, at.Format(time.RFC3339), err)
+			continue
+		}
+		return rec.Close, rec.Time, nil
+	}
+	if noMatch != nil {
+		return 0, time.Time{}, noMatch
+	}
+	return 0, time.Time{}, fmt.Errorf("%w: no %s/%s rate within %s before %s", ErrNoData, assetClass, symbol, staleness, at.Format(time.RFC3339))
+}
+
+// triangulate chains the forex pairs the source holds into the shortest
+// paths from one currency to another, of at most maxFXLegs legs, and
+// returns the legs of the one whose stalest rate is closest to at.
+func (c *FXConverter) triangulate(ctx context.Context, from, to string, at time.Time) ([]Leg, error) {
+	lister, ok := c.source.(SymbolLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the data source cannot list forex pairs", ErrNoData)
+	}
+	symbols, err := lister.Symbols(ctx, c.cfg.ForexDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var best []Leg
+	var bestAge time.Duration
+	var lastErr error
+	for _, path := range newCurrencyGraph(symbols).shortestPaths(from, to, maxFXLegs, maxFXPaths) {
+		legs := make([]Leg, 0, len(path)-1)
+		for i := 1; i < len(path); i++ {
+			leg, err := c.leg(ctx, path[i-1], path[i], at)
+			if err != nil {
+				lastErr = err
+				legs = nil
+				break
+			}
+			legs = append(legs, leg)
+		}
+		if legs == nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		age := newConversion(1, at, legs).RateDate.Sub(at).Abs()
+		if best == nil || age < bestAge {
+			best, bestAge = legs, age
+		}
+	}
+
+	if best == nil {
+		if lastErr != nil {
+			return nil, fmt.Errorf("cannot triangulate %s to %s: %w", from, to, lastErr)
+		}
+		return nil, fmt.Errorf("%w: no path of forex pairs from %s to %s", ErrNoData, from, to)
+	}
+	return best, nil
+}
+
+// currencyGraph links every currency to the currencies it has a forex pair
+// with, in either direction.
+type currencyGraph map[string][]string
+
+// newCurrencyGraph builds the graph of the <BASE>_<QUOTE> symbols among
+// symbols. Other symbols are ignored.
+func newCurrencyGraph(symbols []string) currencyGraph {
+	g := make(currencyGraph)
+	seen := make(map[[2]string]bool)
+	link := func(a, b string) {
+		if !seen[[2]string{a, b}] {
+			seen[[2]string{a, b}] = true
+			g[a] = append(g[a], b)
+		}
+	}
+	for _, symbol := range symbols {
+		parts := strings.Split(symbol, "_")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || parts[0] == parts[1] {
+			continue
+		}
+		link(parts[0], parts[1])
+		link(parts[1], parts[0])
+	}
+	for _, neighbours := range g {
+		sort.Strings(neighbours)
+	}
+	return g
+}
+
+// shortestPaths returns up to limit paths of the fewest possible legs, no
+// more than maxLegs, from one currency to another. Each path lists the
+// currencies it passes through, starting with from and ending with to.
+func (g currencyGraph) shortestPaths(from, to string, maxLegs, limit int) [][]string {
+	// Breadth first search recording every predecessor on a shortest path.
+	depth := map[string]int{from: 0}
+	parents := make(map[string][]string)
+	frontier := []string{from}
+	for d := 1; d <= maxLegs && len(frontier) > 0 && depth[to] == 0; d++ {
+		var next []string
+		for _, node := range frontier {
+			for _, neighbour := range g[node] {
+				nd, visited := depth[neighbour]
+				if !visited {
+					depth[neighbour] = d
+					next = append(next, neighbour)
+				}
+				if !visited || nd == d {
+					parents[neighbour] = append(parents[neighbour], node)
+				}
+			}
+		}
+		frontier = next
+	}
+	if _, ok := depth[to]; !ok || from == to {
+		return nil
+	}
+
+	var paths [][]string
+	var walk func(node string, suffix []string)
+	walk = func(node string, suffix []string) {
+		if len(paths) >= limit {
+			return
+		}
+		suffix = append([]string{node}, suffix...)
+		if node == from {
+			paths = append(paths, suffix)
+			return
+		}
+		for _, parent := range parents[node] {
+			walk(parent, suffix)
+		}
+	}
+	walk(to, nil)
+	return paths
+}
+
+// converter returns the FXConverter used by the package level lookups.
+func converter() *FXConverter {
+	return NewFXConverter(source, config, registry)
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRateDays bounds the days of series an FXConverter keeps in memory.
+const maxRateDays = 64
+
+// rateKey names one UTC day of a series at one interval.
+type rateKey struct {
+	assetClass, symbol, interval string
+	day                          int64
+}
+
+// rateDay holds the candles of a series for one UTC day, along with the
+// last candle before the day within the staleness window, so that rates as
+// of any time of the day can be read from it.
+type rateDay struct {
+	once    sync.Once
+	records []Record
+	prior   *Record
+	err     error
+}
+
+// rateMemo keeps the most recently read days of the series converted with,
+// evicting the oldest beyond maxRateDays.
+type rateMemo struct {
+	mu    sync.Mutex
+	days  map[rateKey]*rateDay
+	order []rateKey
+}
+
+func newRateMemo() *rateMemo {
+	return &rateMemo{days: make(map[rateKey]*rateDay)}
+}
+
+func dayOf(t time.Time) int64 {
+	return t.UTC().Truncate(24 * time.Hour).Unix()
+}
+
+// day returns the day of series named by key, reading it from src on first
+// use. Concurrent callers wait for a single read.
+func (m *rateMemo) day(ctx context.Context, src DataSource, key rateKey, staleness time.Duration) (*rateDay, error) {
+	m.mu.Lock()
+	d, ok := m.days[key]
+	if !ok {
+		if len(m.order) >= maxRateDays {
+			delete(m.days, m.order[0])
+			m.order = m.order[1:]
+		}
+		d = &rateDay{}
+		m.days[key] = d
+		m.order = append(m.order, key)
+	}
+	m.mu.Unlock()
+
+	d.once.Do(func() {
+		start := time.Unix(key.day, 0).UTC()
+		records, err := src.Candles(ctx, key.assetClass, key.symbol, key.interval, start.Add(-staleness), start.Add(24*time.Hour-time.Nanosecond))
+		if errors.Is(err, ErrNoData) {
+			return
+		}
+		if err != nil {
+			d.err = err
+			return
+		}
+
+		i := sort.Search(len(records), func(i int) bool { return !records[i].Time.Before(start) })
+		if i > 0 {
+			prior := records[i-1]
+			d.prior = &prior
+		}
+		d.records = append([]Record(nil), records[i:]...)
+	})
+	return d, d.err
+}
+
+// asOf returns the last candle at or before at and no older than
+// staleness. It fails with ErrNoData when the day holds no candle up to at,
+// and with ErrNoMatch when the latest one is too old.
+func (d *rateDay) asOf(at time.Time, staleness time.Duration) (Record, error) {
+	latest := d.prior
+	if n := sort.Search(len(d.records), func(i int) bool { return d.records[i].Time.After(at) }); n > 0 {
+		latest = &d.records[n-1]
+	}
+
+	switch {
+	case latest == nil:
+		return Record{}, ErrNoData
+	case at.Sub(latest.Time) > staleness:
+		return Record{}, ErrNoMatch
+	}
+	return *latest, nil
+}
@@ -0,0 +1,374 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotedSymbolsConvertToUSD(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("forex", "EUR_USD", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 1.08},
+		Record{Date: "2024-06-01T01:00:00Z", Close: 1.09},
+	)
+	src.Add("forex", "GBP_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.27})
+	src.Add("forex", "JPY_USD", "1d", Record{Date: "2024-06-01T00:00:00Z", Close: 0.0064})
+	src.Add("stocks", "SAP_EUR", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 170},
+		Record{Date: "2024-06-01T01:00:00Z", Close: 171},
+	)
+	src.Add("stocks", "BARC_GBP", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 2.1})
+	src.Add("stocks", "SONY_JPY", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 12500})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T01:00:00Z")
+
+	tests := []struct {
+		symbol       string
+		wantUSD      float64
+		wantRate     float64
+		wantRateDate string
+	}{
+		{symbol: "SAP_EUR", wantUSD: 171 * 1.09, wantRate: 1.09, wantRateDate: "2024-06-01T01:00:00Z"},
+		{symbol: "BARC_GBP", wantUSD: 2.1 * 1.27, wantRate: 1.27, wantRateDate: "2024-06-01T00:00:00Z"},
+		{symbol: "SONY_JPY", wantUSD: 12500 * 0.0064, wantRate: 0.0064, wantRateDate: "2024-06-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			result, err := GetCloseUSD(context.Background(), "stocks", tt.symbol, date, Options{Policy: PolicyPrevious})
+			if err != nil {
+				t.Fatalf("GetCloseUSD returned error: %v", err)
+			}
+			if result.ClosePriceUSD == nil || math.Abs(*result.ClosePriceUSD-tt.wantUSD) > 1e-9 {
+				t.Errorf("ClosePriceUSD = %v, want %v", result.ClosePriceUSD, tt.wantUSD)
+			}
+			if result.Metadata.ConversionRate != tt.wantRate || result.Metadata.ConversionRateDate != tt.wantRateDate {
+				t.Errorf("rate = %v at %s, want %v at %s", result.Metadata.ConversionRate, result.Metadata.ConversionRateDate, tt.wantRate, tt.wantRateDate)
+			}
+
+			// The range lookup must convert each candle the same way.
+			rng, err := GetCloseInBetween(context.Background(), "stocks", tt.symbol, mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-01T01:00:00Z"), Options{Policy: PolicyPrevious})
+			if err != nil {
+				t.Fatalf("GetCloseInBetween returned error: %v", err)
+			}
+			last := rng.ClosePrices[len(rng.ClosePrices)-1]
+			if last.ClosePriceUSD == nil || math.Abs(*last.ClosePriceUSD-tt.wantUSD) > 1e-9 || last.Metadata.ConversionRate != tt.wantRate {
+				t.Errorf("range detail = %+v, want %v at rate %v", last, tt.wantUSD, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestFXConverterConvert(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.08})
+	fx := NewFXConverter(src, DefaultConfig(), DefaultRegistry())
+	at, _ := time.Parse(time.RFC3339, "2024-06-01T00:20:00Z")
+
+	conv, err := fx.Convert(context.Background(), 10, "EUR", "USD", at)
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if math.Abs(conv.Amount-10.8) > 1e-9 || conv.Rate != 1.08 {
+		t.Errorf("unexpected conversion: %+v", conv)
+	}
+
+	same, err := fx.Convert(context.Background(), 10, "USD", "USD", at)
+	if err != nil || same.Amount != 10 || same.Rate != 1 {
+		t.Errorf("same currency conversion = %+v, %v", same, err)
+	}
+
+	if _, err := fx.Convert(context.Background(), 10, "CHF", "USD", at); err == nil {
+		t.Errorf("expected an error for a missing pair")
+	}
+}
+
+func TestGetCloseUSDInQuoteCurrency(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.25})
+	src.Add("crypto", "BTC_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 70000})
+	src.Add("stocks", "SAP_EUR", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 170})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T00:00:00Z")
+
+	tests := []struct {
+		assetClass string
+		symbol     string
+		quote      string
+		want       float64
+		wantRate   float64
+	}{
+		// USD_EUR is read by inverting the EUR_USD series.
+		{assetClass: "crypto", symbol: "BTC_USDT", quote: "eur", want: 56000, wantRate: 0.8},
+		{assetClass: "stocks", symbol: "SAP_EUR", quote: "EUR", want: 170, wantRate: 1},
+		{assetClass: "stocks", symbol: "SAP_EUR", quote: "", want: 212.5, wantRate: 1.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol+"_in_"+tt.quote, func(t *testing.T) {
+			result, err := GetCloseUSD(context.Background(), tt.assetClass, tt.symbol, date, Options{QuoteCurrency: tt.quote})
+			if err != nil {
+				t.Fatalf("GetCloseUSD returned error: %v", err)
+			}
+			if math.Abs(result.ClosePrice-tt.want) > 1e-9 || math.Abs(result.Metadata.ConversionRate-tt.wantRate) > 1e-12 {
+				t.Errorf("got %v at rate %v, want %v at rate %v", result.ClosePrice, result.Metadata.ConversionRate, tt.want, tt.wantRate)
+			}
+
+			wantCurrency := "USD"
+			if tt.quote != "" {
+				wantCurrency = "EUR"
+			}
+			if result.Currency != wantCurrency {
+				t.Errorf("Currency = %q, want %q", result.Currency, wantCurrency)
+			}
+			if (wantCurrency == "USD") != (result.ClosePriceUSD != nil) {
+				t.Errorf("ClosePriceUSD = %v for currency %s", result.ClosePriceUSD, wantCurrency)
+			}
+		})
+	}
+}
+
+func TestFXConverterTriangulates(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("forex", "TRY_EUR", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 0.03})
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.1})
+	// A path through GBP is as short but its rate is a day older.
+	src.Add("forex", "TRY_GBP", "1h", Record{Date: "2024-05-31T00:00:00Z", Close: 0.025})
+	src.Add("forex", "USD_GBP", "1h", Record{Date: "2024-05-31T00:00:00Z", Close: 0.8})
+	fx := NewFXConverter(src, DefaultConfig(), DefaultRegistry())
+	at, _ := time.Parse(time.RFC3339, "2024-06-01T00:00:00Z")
+
+	conv, err := fx.Convert(context.Background(), 100, "TRY", "USD", at)
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if math.Abs(conv.Amount-3.3) > 1e-9 {
+		t.Errorf("Amount = %v, want 3.3", conv.Amount)
+	}
+	if len(conv.Legs) != 2 || conv.Legs[0].Pair != "TRY_EUR" || conv.Legs[1].Pair != "EUR_USD" {
+		t.Fatalf("Legs = %+v, want TRY_EUR then EUR_USD", conv.Legs)
+	}
+
+	// Without the EUR legs the GBP path is used, inverting USD_GBP.
+	src = NewMemorySource()
+	src.Add("forex", "TRY_GBP", "1h", Record{Date: "2024-05-31T00:00:00Z", Close: 0.025})
+	src.Add("forex", "USD_GBP", "1h", Record{Date: "2024-05-31T00:00:00Z", Close: 0.8})
+	fx = NewFXConverter(src, DefaultConfig(), DefaultRegistry())
+
+	conv, err = fx.Convert(context.Background(), 100, "TRY", "USD", at)
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if math.Abs(conv.Rate-0.025/0.8) > 1e-12 || conv.RateDate.Format(time.RFC3339) != "2024-05-31T00:00:00Z" {
+		t.Errorf("conversion = %+v, want rate %v dated 2024-05-31", conv, 0.025/0.8)
+	}
+
+	if _, err := fx.Convert(context.Background(), 1, "CHF", "USD", at); !errors.Is(err, ErrNoData) {
+		t.Errorf("Convert(CHF) error = %v, want ErrNoData", err)
+	}
+}
+
+func TestCurrencyGraphShortestPaths(t *testing.T) {
+	g := newCurrencyGraph([]string{"A_B", "B_C", "C_D", "A_E", "E_C", "BTC_USD_PERP"})
+
+	paths := g.shortestPaths("A", "D", 3, 8)
+	want := [][]string{{"A", "B", "C", "D"}, {"A", "E", "C", "D"}}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("shortestPaths(A, D) = %v, want %v", paths, want)
+	}
+	if paths := g.shortestPaths("A", "D", 2, 8); paths != nil {
+		t.Errorf("shortestPaths(A, D) within 2 legs = %v, want none", paths)
+	}
+}
+
+func TestCryptoQuotedPairsUseAssetTree(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "SOL_BTC", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 0.0025},
+		Record{Date: "2024-06-01T02:00:00Z", Close: 0.0026},
+	)
+	src.Add("crypto", "BTC_USDT", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 68000},
+		Record{Date: "2024-06-01T02:00:00Z", Close: 69000},
+	)
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.25})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T01:00:00Z")
+
+	// The previous policy of the request must also pick the BTC price.
+	result, err := GetCloseUSD(context.Background(), "crypto", "SOL_BTC", date, Options{Policy: PolicyPrevious})
+	if err != nil {
+		t.Fatalf("GetCloseUSD returned error: %v", err)
+	}
+	if math.Abs(result.ClosePrice-0.0025*68000) > 1e-9 {
+		t.Errorf("ClosePrice = %v, want %v", result.ClosePrice, 0.0025*68000)
+	}
+	legs := result.Metadata.ConversionLegs
+	if len(legs) != 1 || legs[0].AssetClass != "crypto" || legs[0].Pair != "BTC_USDT" || legs[0].RateDate != "2024-06-01T00:00:00Z" {
+		t.Errorf("ConversionLegs = %+v, want the 00:00 BTC_USDT close", legs)
+	}
+
+	result, err = GetCloseUSD(context.Background(), "crypto", "SOL_BTC", date, Options{Policy: PolicyPrevious, QuoteCurrency: "EUR"})
+	if err != nil {
+		t.Fatalf("GetCloseUSD in EUR returned error: %v", err)
+	}
+	if math.Abs(result.ClosePrice-0.0025*68000/1.25) > 1e-9 || len(result.Metadata.ConversionLegs) != 2 {
+		t.Errorf("got %v via %+v, want %v via BTC_USDT and EUR_USD", result.ClosePrice, result.Metadata.ConversionLegs, 0.0025*68000/1.25)
+	}
+}
+
+func TestCryptoQuoteAssetHonoursMaxStaleness(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "SOL_BTC", "1h", Record{Date: "2024-06-01T10:00:00Z", Close: 0.0025})
+	src.Add("crypto", "BTC_USDT", "1h", Record{Date: "2024-06-01T02:00:00Z", Close: 68000})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T10:00:00Z")
+
+	// The 8 hour old BTC price is within Config.FXStaleness...
+	if _, err := GetCloseUSD(context.Background(), "crypto", "SOL_BTC", date, Options{}); err != nil {
+		t.Fatalf("GetCloseUSD returned error: %v", err)
+	}
+
+	// ...but not within the staleness the request allows.
+	_, err := GetCloseUSD(context.Background(), "crypto", "SOL_BTC", date, Options{MaxStaleness: time.Hour})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("GetCloseUSD with a stale BTC_USDT candle = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestConversionRatesAreReadAsOf(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_EUR", "1h",
+		Record{Date: "2024-06-03T13:00:00Z", Close: 0.4},
+		Record{Date: "2024-06-03T23:00:00Z", Close: 0.5},
+	)
+	// Daily candles, with a weekend gap before Monday's.
+	src.Add("forex", "EUR_USD", "1d",
+		Record{Date: "2024-05-31T00:00:00Z", Close: 1.05},
+		Record{Date: "2024-06-03T00:00:00Z", Close: 1.1},
+		Record{Date: "2024-06-04T00:00:00Z", Close: 1.2},
+	)
+	withSource(t, src)
+
+	tests := []struct {
+		name     string
+		date     string
+		opts     Options
+		wantRate float64
+	}{
+		{name: "exact price, daily rate", date: "2024-06-03T13:00:00Z", opts: Options{Policy: PolicyExact}, wantRate: 1.1},
+		// The nearest rate to 23:00 is the next day's, which was not known yet.
+		{name: "no look-ahead", date: "2024-06-03T23:00:00Z", opts: Options{Policy: PolicyNearest}, wantRate: 1.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, _ := time.Parse(time.RFC3339, tt.date)
+			result, err := GetCloseUSD(context.Background(), "crypto", "ADA_EUR", date, tt.opts)
+			if err != nil {
+				t.Fatalf("GetCloseUSD returned error: %v", err)
+			}
+			if result.Metadata.ConversionRate != tt.wantRate || result.Metadata.ConversionRateDate != "2024-06-03T00:00:00Z" {
+				t.Errorf("rate = %v at %s, want %v at 2024-06-03", result.Metadata.ConversionRate, result.Metadata.ConversionRateDate, tt.wantRate)
+			}
+		})
+	}
+
+	// Over the weekend the rate is Friday's, within the default FXStaleness.
+	fx := NewFXConverter(src, DefaultConfig(), DefaultRegistry())
+	sunday := time.Date(2024, 6, 2, 20, 0, 0, 0, time.UTC)
+	conv, err := fx.Convert(context.Background(), 1, "EUR", "USD", sunday)
+	if err != nil || conv.Rate != 1.05 {
+		t.Errorf("Sunday conversion = %+v, %v, want Friday's 1.05", conv, err)
+	}
+}
+
+func TestSeriesRateFallsBackToNextInterval(t *testing.T) {
+	src := NewMemorySource()
+	// The hourly series only has a candle after the requested time.
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T12:00:00Z", Close: 1.3})
+	src.Add("forex", "EUR_USD", "1d", Record{Date: "2024-06-01T00:00:00Z", Close: 1.1})
+	fx := NewFXConverter(src, DefaultConfig(), DefaultRegistry())
+
+	conv, err := fx.Convert(context.Background(), 1, "EUR", "USD", time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC))
+	if err != nil || conv.Rate != 1.1 {
+		t.Errorf("conversion = %+v, %v, want the daily 1.1", conv, err)
+	}
+}
+
+// countingSource counts the Candles calls made for each asset class.
+type countingSource struct {
+	DataSource
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *countingSource) Candles(ctx context.Context, assetClass, symbol, interval string, from, to time.Time) ([]Record, error) {
+	s.mu.Lock()
+	s.calls[assetClass]++
+	s.mu.Unlock()
+	return s.DataSource.Candles(ctx, assetClass, symbol, interval, from, to)
+}
+
+func TestRangeReadsForexSeriesOncePerDay(t *testing.T) {
+	mem := NewMemorySource()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var prices, rates []Record
+	for m := 0; m < 2*24*60; m++ {
+		date := start.Add(time.Duration(m) * time.Minute).Format(time.RFC3339)
+		prices = append(prices, Record{Date: date, Close: 170})
+		rates = append(rates, Record{Date: date, Close: 1.1})
+	}
+	mem.Add("stocks", "SAP_EUR", "1m", prices...)
+	mem.Add("forex", "EUR_USD", "1m", rates...)
+	src := &countingSource{DataSource: mem, calls: make(map[string]int)}
+	withSource(t, src)
+
+	rng, err := GetCloseInBetween(context.Background(), "stocks", "SAP_EUR", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-02T23:59:00Z"), Options{Candle: "1m", Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rng.ClosePrices) != 2*24*60 {
+		t.Fatalf("got %d closes, want %d", len(rng.ClosePrices), 2*24*60)
+	}
+	// One read of the 1m EUR_USD series for each of the two days.
+	if n := src.calls["forex"]; n != 2 {
+		t.Errorf("read the forex series %d times for %d closes, want 2", n, len(rng.ClosePrices))
+	}
+}
+
+func TestLegacyUSDFields(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "DEAD_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 0})
+	src.Add("forex", "EUR_USD", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 1.25})
+	withSource(t, src)
+
+	// A zero USD price is still reported.
+	rng, err := GetCloseInBetween(context.Background(), "crypto", "DEAD_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-01T00:00:00Z"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(rng)
+	if !strings.Contains(string(data), `"closePricesUSD":[`) || !strings.Contains(string(data), `"closePriceUSD":0`) {
+		t.Errorf("USD response = %s, want closePricesUSD with closePriceUSD 0", data)
+	}
+
+	rng, err = GetCloseInBetween(context.Background(), "crypto", "DEAD_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-01T00:00:00Z"), Options{QuoteCurrency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(rng)
+	if strings.Contains(string(data), "closePriceUSD") || strings.Contains(string(data), "closePricesUSD") {
+		t.Errorf("EUR response = %s, want no USD fields", data)
+	}
+}
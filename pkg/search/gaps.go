@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// maxGapIssues caps each list of a GapReport so that a badly broken file
+// does not produce an unbounded report.
+const maxGapIssues = 1000
+
+// GapReport lists the problems found in the dated files of one symbol at
+// one interval.
+type GapReport struct {
+	AssetClass string `json:"assetClass"`
+	Symbol     string `json:"symbol"`
+	Interval   string `json:"interval"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	// Days is the number of days examined.
+	Days int `json:"days"`
+	// MissingDays are the days without a file at the interval.
+	MissingDays []string `json:"missingDays"`
+	// MissingCandles are the runs of candles absent between two candles of
+	// the same day.
+	MissingCandles []CandleGap `json:"missingCandles"`
+	// Duplicates are the rows repeating the timestamp of an earlier row of
+	// their file.
+	Duplicates []RowIssue `json:"duplicates"`
+	// OutOfOrder are the rows dated before the row preceding them.
+	OutOfOrder []RowIssue `json:"outOfOrder"`
+	// Truncated reports whether a list was cut at maxGapIssues entries.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// CandleGap is a run of consecutive missing candles.
+type CandleGap struct {
+	// From and To are the dates of the first and last missing candle.
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Missing int    `json:"missing"`
+}
+
+// RowIssue points at one row of a CSV file.
+type RowIssue struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Date string `json:"date"`
+}
+
+// Clean reports whether no problem was found.
+func (r GapReport) Clean() bool {
+	return len(r.MissingDays) == 0 && len(r.MissingCandles) == 0 && len(r.Duplicates) == 0 && len(r.OutOfOrder) == 0
+}
+
+// AnalyzeGaps examines the dated files of symbol at interval for every day
+// in [from, to] under the configured data root. It reads the files
+// directly rather than through the DataSource, since duplicated and
+// misordered rows are no longer visible once records are sorted.
+//
+// Missing candles are only counted between the first and last candle of a
+// day, so sessions that do not trade around the clock are not reported as
+// gaps; candles of a day or longer are not checked within days.
+func AnalyzeGaps(ctx context.Context, assetClass, symbol, interval string, from, to time.Time) (GapReport, error) {
+	length, err := parseInterval(interval)
+	if err != nil {
+		return GapReport{}, err
+	}
+	if to.Before(from) {
+		return GapReport{}, fmt.Errorf("%w: end date is before start date", ErrInvalidRequest)
+	}
+
+	report := GapReport{
+		AssetClass:     assetClass,
+		Symbol:         symbol,
+		Interval:       interval,
+		From:           from.UTC().Format(time.RFC3339),
+		To:             to.UTC().Format(time.RFC3339),
+		MissingDays:    []string{},
+		MissingCandles: []CandleGap{},
+		Duplicates:     []RowIssue{},
+		OutOfOrder:     []RowIssue{},
+	}
+
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return GapReport{}, err
+		}
+		report.Days++
+
+		path, err := candlePath(config, assetClass, symbol, interval, day)
+		if err != nil {
+			return GapReport{}, err
+		}
+		records, lines, err := readCSVRows(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			report.addMissingDay(day.Format("2006-01-02"))
+			continue
+		}
+		if err != nil {
+			return GapReport{}, err
+		}
+
+		report.checkRows(path, records, lines)
+		if length < 24*time.Hour {
+			report.checkCandles(records, length)
+		}
+	}
+	return report, nil
+}
+
+// checkRows reports the duplicated and misordered rows of a file.
+func (r *GapReport) checkRows(path string, records []Record, lines []int) {
+	seen := make(map[int64]bool, len(records))
+	for i, rec := range records {
+		issue := RowIssue{File: path, Line: lines[i], Date: rec.Date}
+		if key := rec.Time.UnixNano(); seen[key] {
+			r.Duplicates = appendIssue(r, r.Duplicates, issue)
+		} else {
+			seen[key] = true
+		}
+		if i > 0 && rec.Time.Before(records[i-1].Time) {
+			r.OutOfOrder = appendIssue(r, r.OutOfOrder, issue)
+		}
+	}
+}
+
+// checkCandles reports the candles missing between consecutive candles of
+// a day's records, which are sorted here without modifying the caller's
+// order.
+func (r *GapReport) checkCandles(records []Record, length time.Duration) {
+	sorted := append([]Record{}, records...)
+	sortRecords(sorted)
+
+	for i := 1; i < len(sorted); i++ {
+		prev, next := sorted[i-1].Time, sorted[i].Time
+		missing := int(next.Sub(prev)/length) - 1
+		if missing <= 0 {
+			continue
+		}
+		gap := CandleGap{
+			From:    prev.Add(length).Format(time.RFC3339),
+			To:      prev.Add(time.Duration(missing) * length).Format(time.RFC3339),
+			Missing: missing,
+		}
+		if len(r.MissingCandles) >= maxGapIssues {
+			r.Truncated = true
+			continue
+		}
+		r.MissingCandles = append(r.MissingCandles, gap)
+	}
+}
+
+func (r *GapReport) addMissingDay(day string) {
+	if len(r.MissingDays) >= maxGapIssues {
+		r.Truncated = true
+		return
+	}
+	r.MissingDays = append(r.MissingDays, day)
+}
+
+func appendIssue(r *GapReport, issues []RowIssue, issue RowIssue) []RowIssue {
+	if len(issues) >= maxGapIssues {
+		r.Truncated = true
+		return issues
+	}
+	return append(issues, issue)
+}
+
+// String summarizes the report in one line.
+func (r GapReport) String() string {
+	return fmt.Sprintf("%s/%s %s from %s to %s: %d days, %d missing days, %d candle gaps, %d duplicates, %d out of order",
+		r.AssetClass, r.Symbol, r.Interval, r.From, r.To, r.Days,
+		len(r.MissingDays), len(r.MissingCandles), len(r.Duplicates), len(r.OutOfOrder))
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeGaps(t *testing.T) {
+	root := t.TempDir()
+	writeCandles(t, root, "crypto/2024/06/01/1h/ADA_USDT.csv",
+		"2024-06-01T00:00:00Z,0,0,0,0.41,0\n"+
+			"2024-06-01T04:00:00Z,0,0,0,0.42,0\n"+
+			"2024-06-01T01:00:00Z,0,0,0,0.43,0\n"+
+			"2024-06-01T05:00:00Z,0,0,0,0.44,0\n"+
+			"2024-06-01T05:00:00Z,0,0,0,0.45,0\n")
+	writeCandles(t, root, "crypto/2024/06/03/1h/ADA_USDT.csv",
+		"2024-06-03T00:00:00Z,0,0,0,0.46,0\n"+
+			"2024-06-03T01:00:00Z,0,0,0,0.47,0\n")
+
+	cfg := DefaultConfig()
+	cfg.DataRoot = root
+	withConfig(t, cfg)
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 3, 23, 59, 0, 0, time.UTC)
+	report, err := AnalyzeGaps(context.Background(), "crypto", "ADA_USDT", "1h", from, to)
+	if err != nil {
+		t.Fatalf("AnalyzeGaps returned error: %v", err)
+	}
+
+	if report.Days != 3 || !reflect.DeepEqual(report.MissingDays, []string{"2024-06-02"}) {
+		t.Errorf("Days = %d, MissingDays = %v, want 3 days missing 2024-06-02", report.Days, report.MissingDays)
+	}
+	wantGaps := []CandleGap{{From: "2024-06-01T02:00:00Z", To: "2024-06-01T03:00:00Z", Missing: 2}}
+	if !reflect.DeepEqual(report.MissingCandles, wantGaps) {
+		t.Errorf("MissingCandles = %+v, want %+v", report.MissingCandles, wantGaps)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Line != 6 || report.Duplicates[0].Date != "2024-06-01T05:00:00Z" {
+		t.Errorf("Duplicates = %+v, want line 6 at 05:00", report.Duplicates)
+	}
+	if len(report.OutOfOrder) != 1 || report.OutOfOrder[0].Line != 4 {
+		t.Errorf("OutOfOrder = %+v, want line 4", report.OutOfOrder)
+	}
+	if report.Clean() {
+		t.Errorf("report with problems is Clean")
+	}
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LookupPolicy decides which candle answers a lookup at a given time.
+type LookupPolicy string
+
+const (
+	// PolicyNearest picks the candle closest in time, before or after.
+	PolicyNearest LookupPolicy = "nearest"
+	// PolicyPrevious picks the last candle at or before the requested time,
+	// the value as known at that time.
+	PolicyPrevious LookupPolicy = "previous"
+	// PolicyNext picks the first candle at or after the requested time.
+	PolicyNext LookupPolicy = "next"
+	// PolicyExact only accepts a candle at exactly the requested time.
+	PolicyExact LookupPolicy = "exact"
+)
+
+// defaultLookupWindow bounds how far from the requested time candles are
+// read when Options.MaxStaleness is not set.
+const defaultLookupWindow = 24 * time.Hour
+
+// ParseLookupPolicy converts a policy name into a LookupPolicy. An empty
+// name selects PolicyNearest, and "as-of" is accepted for PolicyPrevious.
+func ParseLookupPolicy(name string) (LookupPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "nearest":
+		return PolicyNearest, nil
+	case "previous", "as-of", "asof":
+		return PolicyPrevious, nil
+	case "next":
+		return PolicyNext, nil
+	case "exact":
+		return PolicyExact, nil
+	}
+	return "", fmt.Errorf("%w: unknown lookup policy %q", ErrInvalidRequest, name)
+}
+
+// Options tune how a lookup selects the candles it reads.
+type Options struct {
+	// Candle is the requested candle interval, e.g. "1h". When empty the
+	// configured intervals are tried in order of priority.
+	Candle string
+	// Strict makes the lookup fail when there is no data at Candle instead
+	// of falling back to the other configured intervals.
+	Strict bool
+	// Policy decides which candle answers a lookup; empty means PolicyNearest.
+	Policy LookupPolicy
+	// MaxStaleness is the largest distance tolerated between the requested
+	// time and the candle used. Zero means no limit beyond the lookup window.
+	MaxStaleness time.Duration
+	// QuoteCurrency is the currency prices are returned in; empty means USD.
+	QuoteCurrency string
+}
+
+func (o Options) quoteCurrency() string {
+	if o.QuoteCurrency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(o.QuoteCurrency)
+}
+
+func (o Options) policy() LookupPolicy {
+	if o.Policy == "" {
+		return PolicyNearest
+	}
+	return o.Policy
+}
+
+// window returns the range of candles to read to answer lookups between
+// from and to under the options' policy.
+func (o Options) window(from, to time.Time) (time.Time, time.Time) {
+	reach := o.MaxStaleness
+	if reach <= 0 {
+		reach = defaultLookupWindow
+	}
+
+	switch o.policy() {
+	case PolicyPrevious:
+		return from.Add(-reach), to
+	case PolicyNext:
+		return from, to.Add(reach)
+	case PolicyExact:
+		return from, to
+	}
+	return from.Add(-reach), to.Add(reach)
+}
+
+// intervals returns the intervals to try, in order, for a lookup.
+func (o Options) intervals(cfg Config) []string {
+	if o.Candle != "" && o.Strict {
+		return []string{o.Candle}
+	}
+
+	intervals := make([]string, 0, len(cfg.Intervals)+2)
+	if o.Candle != "" {
+		intervals = append(intervals, o.Candle)
+	}
+	for _, interval := range cfg.Intervals {
+		if interval != o.Candle {
+			intervals = append(intervals, interval)
+		}
+	}
+	if o.Candle != IntervalAll {
+		intervals = append(intervals, IntervalAll)
+	}
+	return intervals
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// Holding is a quantity of one symbol.
+type Holding struct {
+	AssetClass     string  `json:"assetClass"`
+	InternalSymbol string  `json:"internalSymbol"`
+	Quantity       float64 `json:"quantity"`
+}
+
+// Position is a holding valued at the close found for the valuation date.
+type Position struct {
+	Holding
+	ClosePrice float64  `json:"closePrice"`
+	Value      float64  `json:"value"`
+	ValueUSD   *float64 `json:"valueUSD,omitempty"`
+	// Stale reports whether the close or the conversion rate lies further
+	// from the valuation date than Config.StaleAfter.
+	Stale    bool     `json:"stale"`
+	Metadata Metadata `json:"metadata"`
+	// Err is the error that prevented valuing the holding, in which case
+	// the position is left out of the portfolio total.
+	Err error `json:"-"`
+}
+
+// Valuation is a portfolio valued at one date in one quote currency.
+type Valuation struct {
+	Date     string   `json:"date"`
+	Currency string   `json:"currency"`
+	Total    float64  `json:"total"`
+	TotalUSD *float64 `json:"totalUSD,omitempty"`
+	// Complete reports whether every position was valued, and so counted
+	// in the total.
+	Complete bool `json:"complete"`
+	// Stale reports whether any position is stale.
+	Stale     bool       `json:"stale"`
+	Positions []Position `json:"positions"`
+}
+
+// ValuePortfolio values holdings at date in the quote currency of opts,
+// looking their closes up as GetCloseUSDBatch does. Positions that cannot
+// be valued keep their error and are left out of the total.
+func ValuePortfolio(ctx context.Context, holdings []Holding, date time.Time, opts Options) (Valuation, error) {
+	staleAfter, err := config.staleAfter()
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	requests := make([]CloseRequest, len(holdings))
+	for i, h := range holdings {
+		requests[i] = CloseRequest{AssetClass: h.AssetClass, InternalSymbol: h.InternalSymbol, Date: date}
+	}
+
+	currency := opts.quoteCurrency()
+	valuation := Valuation{
+		Date:      date.UTC().Format(time.RFC3339),
+		Currency:  currency,
+		Complete:  true,
+		Positions: make([]Position, len(holdings)),
+	}
+	for i, res := range GetCloseUSDBatch(ctx, requests, opts) {
+		pos := Position{Holding: holdings[i], Err: res.Err}
+		if res.Err != nil {
+			valuation.Complete = false
+			valuation.Positions[i] = pos
+			continue
+		}
+
+		pos.ClosePrice = res.Response.ClosePrice
+		pos.Value = pos.Quantity * pos.ClosePrice
+		pos.ValueUSD = usdAmount(pos.Value, currency)
+		pos.Metadata = res.Response.Metadata
+		pos.Stale = staleAfter > 0 && stale(date, pos.Metadata, staleAfter)
+
+		valuation.Total += pos.Value
+		valuation.Stale = valuation.Stale || pos.Stale
+		valuation.Positions[i] = pos
+	}
+	valuation.TotalUSD = usdAmount(valuation.Total, currency)
+	return valuation, nil
+}
+
+// stale reports whether the close or the conversion rate described by
+// metadata lies further than limit from date.
+func stale(date time.Time, metadata Metadata, limit time.Duration) bool {
+	for _, s := range []string{metadata.FetchedDate, metadata.ConversionRateDate} {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			continue
+		}
+		if d := date.Sub(t); d > limit || d < -limit {
+			return true
+		}
+	}
+	return false
+}
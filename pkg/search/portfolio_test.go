@@ -0,0 +1,57 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValuePortfolio(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StaleAfter = "1h"
+	withConfig(t, cfg)
+
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 0.5})
+	src.Add("crypto", "SOL_USDT", "1h", Record{Date: "2024-05-31T20:00:00Z", Close: 150})
+	withSource(t, src)
+
+	holdings := []Holding{
+		{AssetClass: "crypto", InternalSymbol: "ADA_USDT", Quantity: 1000},
+		{AssetClass: "crypto", InternalSymbol: "SOL_USDT", Quantity: 2},
+		{AssetClass: "crypto", InternalSymbol: "NOPE_USDT", Quantity: 1},
+	}
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	valuation, err := ValuePortfolio(context.Background(), holdings, date, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(valuation.Total-800) > 1e-9 || valuation.TotalUSD == nil || *valuation.TotalUSD != valuation.Total {
+		t.Errorf("total = %v (USD %v), want 800", valuation.Total, valuation.TotalUSD)
+	}
+	if valuation.Complete || !valuation.Stale {
+		t.Errorf("complete = %v, stale = %v, want false, true", valuation.Complete, valuation.Stale)
+	}
+
+	ada, sol, nope := valuation.Positions[0], valuation.Positions[1], valuation.Positions[2]
+	if ada.Value != 500 || ada.Stale || ada.Metadata.FetchedDate != "2024-06-01T00:00:00Z" {
+		t.Errorf("ADA position = %+v, want a fresh value of 500", ada)
+	}
+	if sol.Value != 300 || !sol.Stale {
+		t.Errorf("SOL position = %+v, want a stale value of 300", sol)
+	}
+	if !errors.Is(nope.Err, ErrUnknownSymbol) || nope.Value != 0 {
+		t.Errorf("NOPE position = %+v, want ErrUnknownSymbol", nope)
+	}
+}
+
+func TestConfigRejectsInvalidStaleAfter(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StaleAfter = "soon"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted an invalid staleAfter")
+	}
+}
@@ -0,0 +1,162 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AssetKind tells how a quote asset is turned into a currency the forex
+// series can convert.
+type AssetKind string
+
+const (
+	// AssetFiat is a currency converted through the forex series as is.
+	AssetFiat AssetKind = "fiat"
+	// AssetStablecoin is a token pegged one to one to a fiat currency.
+	AssetStablecoin AssetKind = "stablecoin"
+	// AssetCrypto is a token that must itself be priced, e.g. BTC in ETH_BTC.
+	AssetCrypto AssetKind = "crypto"
+)
+
+// Asset describes a quote asset.
+type Asset struct {
+	Kind AssetKind `json:"kind"`
+	// Peg is the fiat currency a stablecoin is pegged to.
+	Peg string `json:"peg,omitempty"`
+	// AssetClass and Symbol name the series a crypto asset is priced from
+	// when no forex series converts it. They default to "crypto" and
+	// <ASSET>_USDT.
+	AssetClass string `json:"assetClass,omitempty"`
+	Symbol     string `json:"symbol,omitempty"`
+}
+
+// series returns the asset class and symbol of the series pricing the
+// crypto asset name.
+func (a Asset) series(name string) (string, string) {
+	assetClass, symbol := a.AssetClass, a.Symbol
+	if assetClass == "" {
+		assetClass = "crypto"
+	}
+	if symbol == "" {
+		symbol = strings.ToUpper(name) + "_USDT"
+	}
+	return assetClass, symbol
+}
+
+// SymbolInfo describes the assets a symbol is traded in.
+type SymbolInfo struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+}
+
+// Registry describes symbols and the quote assets they are priced in.
+// Symbols missing from it are read as <BASE>_<QUOTE>, and quote assets
+// missing from it are treated as fiat currencies.
+type Registry struct {
+	Assets  map[string]Asset      `json:"assets"`
+	Symbols map[string]SymbolInfo `json:"symbols"`
+}
+
+// DefaultRegistry returns the registry used when no registry file is
+// configured: the common USD stablecoins and the crypto assets pairs are
+// usually quoted in.
+func DefaultRegistry() Registry {
+	usd := Asset{Kind: AssetStablecoin, Peg: "USD"}
+	return Registry{
+		Assets: map[string]Asset{
+			"USDT":  usd,
+			"USDC":  usd,
+			"BUSD":  usd,
+			"FDUSD": usd,
+			"TUSD":  usd,
+			"DAI":   usd,
+			"BTC":   {Kind: AssetCrypto},
+			"ETH":   {Kind: AssetCrypto},
+		},
+		Symbols: map[string]SymbolInfo{},
+	}
+}
+
+// LoadRegistry reads a JSON registry file. Its assets and symbols are
+// added to those of DefaultRegistry, replacing entries of the same name.
+func LoadRegistry(path string) (Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Registry{}, fmt.Errorf("failed to read registry file: %v", err)
+	}
+
+	var file Registry
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Registry{}, fmt.Errorf("failed to parse registry file %s: %v", path, err)
+	}
+
+	reg := DefaultRegistry()
+	for name, asset := range file.Assets {
+		reg.Assets[strings.ToUpper(name)] = asset
+	}
+	for name, info := range file.Symbols {
+		reg.Symbols[name] = info
+	}
+	return reg, reg.Validate()
+}
+
+// Validate reports whether every entry of the registry is complete.
+func (r Registry) Validate() error {
+	for name, asset := range r.Assets {
+		switch asset.Kind {
+		case AssetFiat, AssetCrypto:
+		case AssetStablecoin:
+			if asset.Peg == "" {
+				return fmt.Errorf("stablecoin %s has no peg", name)
+			}
+		default:
+			return fmt.Errorf("asset %s has unknown kind %q", name, asset.Kind)
+		}
+	}
+	for name, info := range r.Symbols {
+		if info.Base == "" || info.Quote == "" {
+			return fmt.Errorf("symbol %s needs both a base and a quote asset", name)
+		}
+	}
+	return nil
+}
+
+// Symbol returns the assets of symbol, from the registry or else from the
+// part after its last underscore. Symbols without an underscore are taken
+// to be quoted in USD.
+func (r Registry) Symbol(symbol string) SymbolInfo {
+	symbol = strings.TrimSuffix(symbol, ".csv")
+	if info, ok := r.Symbols[symbol]; ok {
+		return info
+	}
+
+	i := strings.LastIndex(symbol, "_")
+	if i < 0 {
+		return SymbolInfo{Base: symbol, Quote: "USD"}
+	}
+	return SymbolInfo{Base: symbol[:i], Quote: symbol[i+1:]}
+}
+
+// Asset returns the description of a quote asset, a fiat currency unless
+// the registry says otherwise.
+func (r Registry) Asset(name string) Asset {
+	if asset, ok := r.Assets[strings.ToUpper(name)]; ok {
+		return asset
+	}
+	return Asset{Kind: AssetFiat}
+}
+
+// quoteCurrency returns the currency the closes of symbol are converted
+// from: its quote asset, or the currency that asset is pegged to.
+func (r Registry) quoteCurrency(symbol string) string {
+	quote := strings.ToUpper(r.Symbol(symbol).Quote)
+	if asset := r.Asset(quote); asset.Kind == AssetStablecoin {
+		return strings.ToUpper(asset.Peg)
+	}
+	return quote
+}
+
+// registry is the Registry used by the package level lookup functions.
+var registry = DefaultRegistry()
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryQuoteCurrency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	body := `{
+		"assets": {"pyusd": {"kind": "stablecoin", "peg": "USD"}},
+		"symbols": {"WBTC": {"base": "WBTC", "quote": "ETH"}}
+	}`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry returned error: %v", err)
+	}
+
+	tests := map[string]string{
+		"ADA_USDT":   "USD",
+		"SOL_USDC":   "USD",
+		"BNB_BUSD":   "USD",
+		"BNB_FDUSD":  "USD",
+		"ETH_BTC":    "BTC",
+		"LINK_ETH":   "ETH",
+		"SAP_EUR":    "EUR",
+		"PAXG_PYUSD": "USD",
+		"WBTC":       "ETH",
+		"AAPL":       "USD",
+	}
+	for symbol, want := range tests {
+		if got := reg.quoteCurrency(symbol); got != want {
+			t.Errorf("quoteCurrency(%s) = %s, want %s", symbol, got, want)
+		}
+	}
+}
+
+func TestLoadRegistryRejectsIncompleteEntries(t *testing.T) {
+	for _, body := range []string{
+		`{"assets": {"XUSD": {"kind": "stablecoin"}}}`,
+		`{"assets": {"XUSD": {"kind": "token"}}}`,
+		`{"symbols": {"FOO": {"base": "FOO"}}}`,
+	} {
+		path := filepath.Join(t.TempDir(), "registry.json")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRegistry(path); err == nil {
+			t.Errorf("LoadRegistry(%s) returned no error", body)
+		}
+	}
+}
+
+func TestGetCloseUSDStablecoinQuotes(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "SOL_USDC", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 160})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T00:00:00Z")
+	result, err := GetCloseUSD(context.Background(), "crypto", "SOL_USDC", date, Options{})
+	if err != nil {
+		t.Fatalf("GetCloseUSD returned error: %v", err)
+	}
+	if result.ClosePrice != 160 || result.Metadata.ConversionRate != 1 {
+		t.Errorf("SOL_USDC = %v at rate %v, want 160 at rate 1", result.ClosePrice, result.Metadata.ConversionRate)
+	}
+}
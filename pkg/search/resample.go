@@ -0,0 +1,177 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session describes where resampled candles start. The zero Session aligns
+// candles to UTC midnight.
+type Session struct {
+	// Timezone is the IANA name of the exchange's time zone, e.g. "America/New_York".
+	Timezone string `json:"timezone"`
+	// Open is the local time of day the session opens, as "15:04".
+	Open string `json:"open"`
+}
+
+// anchor returns the location and the offset from local midnight that
+// candle boundaries are aligned to.
+func (s Session) anchor() (*time.Location, time.Duration, error) {
+	loc := time.UTC
+	if s.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid session timezone %q: %v", s.Timezone, err)
+		}
+	}
+
+	var open time.Duration
+	if s.Open != "" {
+		t, err := time.Parse("15:04", s.Open)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid session open %q: %v", s.Open, err)
+		}
+		open = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return loc, open, nil
+}
+
+// bucketStart returns the start of the interval long candle containing t.
+// Candles shorter than a day are counted from the most recent session open;
+// daily and longer candles start at a session open, with weeks starting on
+// Monday.
+func (s Session) bucketStart(t time.Time, interval time.Duration) (time.Time, error) {
+	loc, open, err := s.anchor()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := t.In(loc)
+	sessionOpen := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).Add(open)
+	if sessionOpen.After(t) {
+		sessionOpen = time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc).Add(open)
+	}
+
+	const day = 24 * time.Hour
+	if interval < day {
+		return sessionOpen.Add(t.Sub(sessionOpen).Truncate(interval)), nil
+	}
+
+	// Count days from a Monday so that weekly candles start on Mondays.
+	days := int(interval / day)
+	y, m, d := sessionOpen.Date()
+	index := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)) / day)
+	back := index % days
+	if back < 0 {
+		back += days
+	}
+	return time.Date(y, m, d-back, 0, 0, 0, 0, loc).Add(open), nil
+}
+
+// parseInterval converts an interval name such as "5m", "1h", "1d" or "1w"
+// into its length.
+func parseInterval(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("%w: invalid interval %q", ErrInvalidRequest, interval)
+	}
+
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w: invalid interval %q", ErrInvalidRequest, interval)
+	}
+
+	var unit time.Duration
+	switch strings.ToLower(interval[len(interval)-1:]) {
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("%w: invalid interval %q", ErrInvalidRequest, interval)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Resample aggregates date-sorted records into candles of the given
+// interval aligned to session: the first Open, highest High, lowest Low,
+// last Close and summed Volume and QuoteVolume of each period.
+func Resample(records []Record, interval string, session Session) ([]Record, error) {
+	length, err := parseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Record
+	var current time.Time
+	for _, rec := range records {
+		start, err := session.bucketStart(rec.Time, length)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(out) == 0 || !start.Equal(current) {
+			current = start
+			rec.Time = start.UTC()
+			rec.Date = rec.Time.Format(time.RFC3339)
+			out = append(out, rec)
+			continue
+		}
+
+		// Highs and lows missing from some records are left out; the open
+		// is that of the first record.
+		last := &out[len(out)-1]
+		if rec.missing&columnHigh == 0 && (last.missing&columnHigh != 0 || rec.High > last.High) {
+			last.High = rec.High
+		}
+		if rec.missing&columnLow == 0 && (last.missing&columnLow != 0 || rec.Low < last.Low) {
+			last.Low = rec.Low
+		}
+		last.missing &= rec.missing | columnOpen
+		last.Close = rec.Close
+		last.Volume += rec.Volume
+		last.QuoteVolume += rec.QuoteVolume
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("no records to resample")
+	}
+	return out, nil
+}
+
+// finerIntervals returns the configured intervals that evenly divide
+// interval, coarsest first, so resampling reads as few rows as possible.
+func finerIntervals(cfg Config, interval string) []string {
+	length, err := parseInterval(interval)
+	if err != nil {
+		return nil
+	}
+
+	type candidate struct {
+		name   string
+		length time.Duration
+	}
+	var candidates []candidate
+	for _, name := range cfg.Intervals {
+		l, err := parseInterval(name)
+		if err != nil || l >= length || length%l != 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{name: name, length: l})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].length > candidates[j].length })
+
+	finer := make([]string, len(candidates))
+	for i, c := range candidates {
+		finer[i] = c.name
+	}
+	return finer
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResample(t *testing.T) {
+	records := []Record{
+		{Date: "2024-06-01T00:00:00Z", Open: 10, High: 11, Low: 9, Close: 10.5, Volume: 1},
+		{Date: "2024-06-01T00:01:00Z", Open: 10.5, High: 12, Low: 10, Close: 11, Volume: 2},
+		{Date: "2024-06-01T00:04:00Z", Open: 11, High: 11.5, Low: 8, Close: 9, Volume: 3},
+		{Date: "2024-06-01T00:05:00Z", Open: 9, High: 9.5, Low: 8.5, Close: 9.2, Volume: 4},
+	}
+
+	got, err := Resample(prepareRecords(records), "5m", Session{})
+	if err != nil {
+		t.Fatalf("Resample returned error: %v", err)
+	}
+
+	want := prepareRecords([]Record{
+		{Date: "2024-06-01T00:00:00Z", Open: 10, High: 12, Low: 8, Close: 9, Volume: 6},
+		{Date: "2024-06-01T00:05:00Z", Open: 9, High: 9.5, Low: 8.5, Close: 9.2, Volume: 4},
+	})
+	if len(got) != len(want) {
+		t.Fatalf("got %d candles, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candle %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSessionBucketStart(t *testing.T) {
+	nyse := Session{Timezone: "America/New_York", Open: "09:30"}
+
+	tests := []struct {
+		name     string
+		session  Session
+		date     string
+		interval string
+		want     string
+	}{
+		{name: "utc hour", session: Session{}, date: "2024-06-03T13:45:00Z", interval: "1h", want: "2024-06-03T13:00:00Z"},
+		{name: "utc day", session: Session{}, date: "2024-06-03T13:45:00Z", interval: "1d", want: "2024-06-03T00:00:00Z"},
+		{name: "utc week starts monday", session: Session{}, date: "2024-06-06T13:45:00Z", interval: "1w", want: "2024-06-03T00:00:00Z"},
+		{name: "exchange hour", session: nyse, date: "2024-06-03T14:45:00Z", interval: "1h", want: "2024-06-03T14:30:00Z"},
+		{name: "exchange day", session: nyse, date: "2024-06-03T14:45:00Z", interval: "1d", want: "2024-06-03T13:30:00Z"},
+		{name: "before exchange open", session: nyse, date: "2024-06-03T12:00:00Z", interval: "1d", want: "2024-06-02T13:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, _ := time.Parse(time.RFC3339, tt.date)
+			length, err := parseInterval(tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.session.bucketStart(date, length)
+			if err != nil {
+				t.Fatalf("bucketStart returned error: %v", err)
+			}
+			if got.UTC().Format(time.RFC3339) != tt.want {
+				t.Errorf("bucketStart = %s, want %s", got.UTC().Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerAliases maps normalized CSV header names to the Record field they
+// hold. Headers are normalized by lowercasing them and dropping spaces,
+// underscores and dashes, so "Close Price" and "close_price" both match
+// "closeprice".
+var headerAliases = map[string]string{
+	"date":       "Date",
+	"datetime":   "Date",
+	"time":       "Date",
+	"timestamp":  "Date",
+	"opentime":   "Date",
+	"open":       "Open",
+	"openprice":  "Open",
+	"o":          "Open",
+	"high":       "High",
+	"highprice":  "High",
+	"h":          "High",
+	"low":        "Low",
+	"lowprice":   "Low",
+	"l":          "Low",
+	"close":      "Close",
+	"closeprice": "Close",
+	"price":      "Close",
+	"rate":       "Close",
+	"c":          "Close",
+	"volume":     "Volume",
+	"vol":        "Volume",
+	"v":          "Volume",
+
+	"quotevolume":      "QuoteVolume",
+	"quoteassetvolume": "QuoteVolume",
+	"quotevol":         "QuoteVolume",
+}
+
+// dateLayouts are the textual date formats accepted besides Unix epochs.
+// Layouts without a zone are read as UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// minEpochTime and maxEpochTime bound the epochs accepted in files, the
+// times whose Unix nanoseconds fit an int64.
+var (
+	minEpochTime = time.Unix(0, math.MinInt64)
+	maxEpochTime = time.Unix(0, math.MaxInt64)
+)
+
+// compactDateLayout is the layout of dates written as YYYYMMDD.
+const compactDateLayout = "20060102"
+
+// ParseError describes a value of a CSV file that could not be read.
+type ParseError struct {
+	File   string
+	Line   int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: column %s: invalid value %q: %v", e.File, e.Line, e.Column, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// maxParseErrors caps how many row errors are reported for one file.
+const maxParseErrors = 20
+
+// schema records which column of a CSV file holds each Record field.
+type schema struct {
+	file    string
+	headers []string
+	columns map[string]int
+}
+
+// newSchema resolves the header row of file. Date and Close columns are
+// required; the others default to zero when absent.
+func newSchema(file string, headers []string) (*schema, error) {
+	s := &schema{file: file, headers: headers, columns: make(map[string]int)}
+	for i, header := range headers {
+		field, ok := headerAliases[normalizeHeader(header)]
+		if !ok {
+			continue
+		}
+		if _, dup := s.columns[field]; dup {
+			return nil, &ParseError{File: file, Line: 1, Column: header, Value: header, Err: fmt.Errorf("more than one column maps to %s", field)}
+		}
+		s.columns[field] = i
+	}
+
+	for _, field := range []string{"Date", "Close"} {
+		if _, ok := s.columns[field]; !ok {
+			return nil, &ParseError{File: file, Line: 1, Err: fmt.Errorf("no %s column in header %v", field, headers)}
+		}
+	}
+	return s, nil
+}
+
+func normalizeHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(header)
+}
+
+// parseRow converts one CSV row, found at line of the file, into a Record.
+func (s *schema) parseRow(row []string, line int) (Record, []error) {
+	var rec Record
+	var errs []error
+
+	value := func(field string) (string, string, bool) {
+		i, ok := s.columns[field]
+		if !ok {
+			return "", "", false
+		}
+		if i >= len(row) {
+			return "", s.headers[i], true
+		}
+		return strings.TrimSpace(row[i]), s.headers[i], true
+	}
+	fail := func(column, v string, err error) {
+		errs = append(errs, &ParseError{File: s.file, Line: line, Column: column, Value: v, Err: err})
+	}
+
+	v, column, _ := value("Date")
+	if t, err := parseTimestamp(v); err != nil {
+		fail(column, v, err)
+	} else {
+		rec.Time = t
+		rec.Date = t.Format(time.RFC3339)
+	}
+
+	numbers := []struct {
+		field    string
+		dst      *float64
+		required bool
+		column   columnSet
+	}{
+		{"Open", &rec.Open, false, columnOpen},
+		{"High", &rec.High, false, columnHigh},
+		{"Low", &rec.Low, false, columnLow},
+		{"Close", &rec.Close, true, 0},
+		{"Volume", &rec.Volume, false, 0},
+		{"QuoteVolume", &rec.QuoteVolume, false, 0},
+	}
+	for _, n := range numbers {
+		v, column, ok := value(n.field)
+		if !ok || (v == "" && !n.required) {
+			rec.missing |= n.column
+			continue
+		}
+		f, err := parseNumber(v)
+		if err != nil {
+			fail(column, v, err)
+			continue
+		}
+		*n.dst = f
+	}
+
+	return rec, errs
+}
+
+// parseNumber parses a finite decimal number.
+func parseNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, errors.New("empty value")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.New("not a number")
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	return f, nil
+}
+
+// parseTimestamp reads a date in one of dateLayouts, a compact date of
+// eight digits such as 20240601, or a Unix epoch in seconds, milliseconds
+// or microseconds told apart by magnitude. The result is in UTC.
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+
+	// Eight digits would be an epoch second of 1970, never a candle date.
+	if len(s) == len(compactDateLayout) && strings.Trim(s, "0123456789") == "" {
+		t, err := time.Parse(compactDateLayout, s)
+		if err != nil {
+			return time.Time{}, errors.New("invalid compact date")
+		}
+		return t, nil
+	}
+
+	if epoch, err := strconv.ParseFloat(s, 64); err == nil {
+		var t time.Time
+		switch abs := math.Abs(epoch); {
+		case abs < 1e11:
+			sec := math.Floor(epoch)
+			t = time.Unix(int64(sec), int64((epoch-sec)*1e9))
+		case abs < 1e14:
+			t = time.UnixMilli(int64(epoch))
+		case abs < 1e17:
+			t = time.UnixMicro(int64(epoch))
+		}
+		// Records are keyed by their time in Unix nanoseconds.
+		if t.IsZero() || t.Before(minEpochTime) || t.After(maxEpochTime) {
+			return time.Time{}, errors.New("epoch out of range")
+		}
+		return t.UTC(), nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date format")
+}
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSVHeaderAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "rfc3339", body: "Date,Open,High,Low,Close,Volume\n2024-06-01T00:00:00Z,1,2,0.5,1.5,10.25\n"},
+		{name: "aliases", body: "timestamp,open,high,low,close_price,vol\n2024-06-01 00:00:00,1,2,0.5,1.5,10.25\n"},
+		{name: "epoch seconds", body: "time,Close Price,Volume\n1717200000,1.5,10.25\n"},
+		{name: "epoch millis", body: "open_time,o,h,l,c,v\n1717200000000,1,2,0.5,1.5,10.25\n"},
+		{name: "compact date", body: "date,open,high,low,close,volume\n20240601,1,2,0.5,1.5,10.25\n"},
+	}
+
+	want := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ADA_USDT.csv")
+			if err := os.WriteFile(path, []byte(tt.body), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := readCSV(path)
+			if err != nil {
+				t.Fatalf("readCSV returned error: %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			rec := records[0]
+			if !rec.Time.Equal(want) || rec.Date != "2024-06-01T00:00:00Z" {
+				t.Errorf("date = %s (%s), want %s", rec.Date, rec.Time, want)
+			}
+			if rec.Close != 1.5 || rec.Volume != 10.25 {
+				t.Errorf("unexpected record: %+v", rec)
+			}
+		})
+	}
+}
+
+func TestReadCSVReportsRowErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ADA_USDT.csv")
+	body := "Date,Open,High,Low,Close,Volume\n" +
+		"2024-06-01T00:00:00Z,1,2,0.5,1.5,10\n" +
+		"2024-06-01T01:00:00Z,1,2,0.5,abc,10\n" +
+		"yesterday,1,2,0.5,1.5,10\n" +
+		"50000000000,1,2,0.5,1.5,10\n"
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readCSV(path)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), ":5: column Date") || !strings.Contains(err.Error(), "epoch out of range") {
+		t.Errorf("error = %v, want the epoch of line 5 reported out of range", err)
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError, got %v", err)
+	}
+	if perr.File != path || perr.Line != 3 || perr.Column != "Close" || perr.Value != "abc" {
+		t.Errorf("unexpected parse error: %+v", perr)
+	}
+
+	if err := os.WriteFile(path, []byte("When,Close\n2024-06-01T00:00:00Z,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCSV(path); !errors.As(err, &perr) || perr.Line != 1 {
+		t.Errorf("expected a header error, got %v", err)
+	}
+}
@@ -1,14 +1,13 @@
 package search
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/blevesearch/bleve"
@@ -21,24 +20,60 @@ type Metadata struct {
 	ConversionRate     float64 `json:"conversionRate"`
 	ConversionRateDate string  `json:"conversionRateDate"`
 	Candle             string  `json:"candle"`
+	ResampledFrom      string  `json:"resampledFrom,omitempty"`
+	// ConversionLegs are the series ConversionRate was built from, more
+	// than one when it was triangulated or went through a crypto asset.
+	ConversionLegs []ConversionLeg `json:"conversionLegs,omitempty"`
 }
 
+// ConversionLeg is one forex pair of a conversion.
+type ConversionLeg struct {
+	AssetClass string  `json:"assetClass"`
+	Pair       string  `json:"pair"`
+	Rate       float64 `json:"rate"`
+	RateDate   string  `json:"rateDate"`
+}
+
+func conversionLegs(conversion Conversion) []ConversionLeg {
+	if len(conversion.Legs) == 0 {
+		return nil
+	}
+	legs := make([]ConversionLeg, len(conversion.Legs))
+	for i, leg := range conversion.Legs {
+		legs[i] = ConversionLeg{AssetClass: leg.AssetClass, Pair: leg.Pair, Rate: leg.Rate, RateDate: leg.RateDate.Format(time.RFC3339)}
+	}
+	return legs
+}
+
+// CloseUSDResponse is a close price in the requested quote currency.
+// ClosePriceUSD repeats ClosePrice when that currency is USD and is absent
+// otherwise.
 type CloseUSDResponse struct {
-	ClosePriceUSD float64  `json:"closePriceUSD"`
+	ClosePrice    float64  `json:"closePrice"`
+	Currency      string   `json:"currency"`
+	ClosePriceUSD *float64 `json:"closePriceUSD,omitempty"`
 	Metadata      Metadata `json:"metadata"`
 }
 
 type ClosePriceDetail struct {
 	Date          string   `json:"date"`
-	ClosePriceUSD float64  `json:"closePriceUSD"`
+	ClosePrice    float64  `json:"closePrice"`
+	ClosePriceUSD *float64 `json:"closePriceUSD,omitempty"`
 	Metadata      Metadata `json:"metadata"`
 }
 
+// CloseInBetweenResponse lists the closes of a range. ClosePricesUSD
+// repeats ClosePrices when the currency is USD, for clients that predate
+// quote currencies.
 type CloseInBetweenResponse struct {
-	ClosePricesUSD []ClosePriceDetail `json:"closePricesUSD"`
+	Currency       string             `json:"currency"`
+	ClosePrices    []ClosePriceDetail `json:"closePrices"`
+	ClosePricesUSD []ClosePriceDetail `json:"closePricesUSD,omitempty"`
 }
 
 type CloseResult struct {
+	ClosePrice         float64 `json:"closePrice"`
+	Currency           string  `json:"currency"`
 	ClosePriceUSD      float64 `json:"closePriceUSD"`
 	FetchedDate        string  `json:"fetchedDate"`
 	ConversionRate     float64 `json:"conversionRate"`
@@ -59,8 +94,8 @@ type CloseRangeResult struct {
 	Candle                  string  `json:"candle"`
 }
 
-func GetCloseUSDJSON(assetClass, internalSymbol string, date time.Time) (string, error) {
-	result, err := GetCloseUSD(assetClass, internalSymbol, date)
+func GetCloseUSDJSON(assetClass, internalSymbol string, date time.Time, opts Options) (string, error) {
+	result, err := GetCloseUSD(context.Background(), assetClass, internalSymbol, date, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to get close USD data: %v", err)
 	}
@@ -73,8 +108,8 @@ func GetCloseUSDJSON(assetClass, internalSymbol string, date time.Time) (string,
 	return string(jsonData), nil
 }
 
-func GetCloseInBetweenJSON(assetClass, internalSymbol, startDate, endDate string) (string, error) {
-	results, err := GetCloseInBetween(assetClass, internalSymbol, startDate, endDate)
+func GetCloseInBetweenJSON(assetClass, internalSymbol string, start, end time.Time, opts Options) (string, error) {
+	results, err := GetCloseInBetween(context.Background(), assetClass, internalSymbol, start, end, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to get close price data in between: %v", err)
 	}
@@ -87,109 +122,183 @@ func GetCloseInBetweenJSON(assetClass, internalSymbol, startDate, endDate string
 	return string(jsonData), nil
 }
 
-func GetCloseUSD(assetClass, internalSymbol string, date time.Time) (CloseUSDResponse, error) {
-	csvFilePath, err := findDataPath(assetClass, internalSymbol, date)
-	if err != nil {
-		return CloseUSDResponse{}, fmt.Errorf("failed to find CSV file path: %v", err)
-	}
+func GetCloseUSD(ctx context.Context, assetClass, internalSymbol string, date time.Time, opts Options) (CloseUSDResponse, error) {
+	return getCloseUSD(ctx, converter(), assetClass, internalSymbol, date, opts)
+}
 
-	assetData, err := readCSV(csvFilePath)
+// getCloseUSD is GetCloseUSD converting with fx, which lookups of a batch
+// share.
+func getCloseUSD(ctx context.Context, fx *FXConverter, assetClass, internalSymbol string, date time.Time, opts Options) (CloseUSDResponse, error) {
+	candle, err := closeAt(ctx, fx, assetClass, internalSymbol, date, opts)
 	if err != nil {
-		return CloseUSDResponse{}, fmt.Errorf("failed to read asset CSV: %v", err)
+		return CloseUSDResponse{}, err
 	}
 
-	rawClosePrice, closestDate, err := findClosestDate(assetData, date)
-	if err != nil {
-		return CloseUSDResponse{}, fmt.Errorf("error finding closest date: %v", err)
+	conversion := candle.conversions[0]
+	return CloseUSDResponse{
+		ClosePrice:    conversion.Amount,
+		Currency:      candle.currency,
+		ClosePriceUSD: usdAmount(conversion.Amount, candle.currency),
+		Metadata:      candle.metadata(0),
+	}, nil
+}
+
+// GetCloseInBetween returns every candle of internalSymbol between start
+// and end at the interval selected by opts, each converted to the quote
+// currency of opts at its own date. Only candles within the range are
+// returned; opts.Policy and opts.MaxStaleness decide whether the range may
+// start or end without a candle at its exact bounds, see rangeEnds.
+func GetCloseInBetween(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options) (CloseInBetweenResponse, error) {
+	if err := checkRange(start, end); err != nil {
+		return CloseInBetweenResponse{}, err
 	}
 
-	baseCurrency := extractBaseCurrency(internalSymbol)
-	closePriceUSD, conversionRate, conversionRateDate, err := getConversionRate(baseCurrency, date, rawClosePrice)
+	rng, err := closeRange(ctx, assetClass, internalSymbol, start, end, opts)
 	if err != nil {
-		return CloseUSDResponse{}, fmt.Errorf("conversion rate error: %v", err)
+		return CloseInBetweenResponse{}, err
 	}
 
-	metadata := Metadata{
-		FetchedDate:        closestDate.Format(time.RFC3339),
-		ConversionRate:     conversionRate,
-		ConversionRateDate: conversionRateDate.Format(time.RFC3339),
-		Candle:             "1d", // Adjust as necessary
+	details := make([]ClosePriceDetail, len(rng.records))
+	for i, row := range rng.records {
+		conversion := rng.conversions[i]
+		details[i] = ClosePriceDetail{
+			Date:          row.Date,
+			ClosePrice:    conversion.Amount,
+			ClosePriceUSD: usdAmount(conversion.Amount, rng.currency),
+			Metadata:      rng.metadata(i),
+		}
 	}
 
-	return CloseUSDResponse{
-		ClosePriceUSD: closePriceUSD,
-		Metadata:      metadata,
-	}, nil
+	resp := CloseInBetweenResponse{
+		Currency:    rng.currency,
+		ClosePrices: details,
+	}
+	if rng.currency == "USD" {
+		resp.ClosePricesUSD = details
+	}
+	return resp, nil
 }
 
-func GetCloseInBetween(assetClass, internalSymbol, startDate, endDate string) (CloseInBetweenResponse, error) {
-	start, err := time.Parse(time.RFC3339, startDate)
-	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("invalid start date format: %v", err)
+// checkRange checks the bounds of a range lookup.
+func checkRange(start, end time.Time) error {
+	if end.Before(start) {
+		return fmt.Errorf("%w: end date is before start date", ErrInvalidRequest)
 	}
+	return nil
+}
 
-	end, err := time.Parse(time.RFC3339, endDate)
-	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("invalid end date format: %v", err)
-	}
+// convertedRange is the candles answering a lookup with the conversion of
+// each close to the quote currency.
+type convertedRange struct {
+	candleSeries
+	currency    string
+	conversions []Conversion
+}
 
-	csvFilePath, err := findDataPath(assetClass, internalSymbol, start)
-	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("failed to find CSV file path: %v", err)
+// metadata describes where the i-th candle and its conversion came from.
+func (rng convertedRange) metadata(i int) Metadata {
+	conversion := rng.conversions[i]
+	candle := rng.interval
+	if rng.records[i].fromAll && rng.resampledFrom == "" {
+		candle = IntervalAll
+	}
+	return Metadata{
+		FetchedDate:        rng.records[i].Date,
+		ConversionRate:     conversion.Rate,
+		ConversionRateDate: conversion.RateDate.Format(time.RFC3339),
+		Candle:             candle,
+		ResampledFrom:      rng.resampledFrom,
+		ConversionLegs:     conversionLegs(conversion),
 	}
+}
 
-	assetData, err := readCSV(csvFilePath)
+// closeAt loads the candle of internalSymbol answering a lookup at date
+// under opts.Policy and converts its close to the quote currency of opts
+// with fx.
+func closeAt(ctx context.Context, fx *FXConverter, assetClass, internalSymbol string, date time.Time, opts Options) (convertedRange, error) {
+	from, to := opts.window(date, date)
+	series, err := fetchCandles(ctx, assetClass, internalSymbol, opts, from, to)
 	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("failed to read asset CSV: %v", err)
+		return convertedRange{}, fmt.Errorf("failed to load asset candles: %w", err)
 	}
 
-	startClosePrice, startClosestDate, err := findClosestDate(assetData, start)
+	i, closestDate, err := findClosestDate(series.records, date, opts.policy(), opts.MaxStaleness)
 	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("error finding closest start date: %v", err)
+		return convertedRange{}, fmt.Errorf("error finding closest date: %w", err)
 	}
+	series.records = series.records[i : i+1]
 
-	endClosePrice, endClosestDate, err := findClosestDate(assetData, end)
+	baseCurrency := registry.quoteCurrency(internalSymbol)
+	currency := opts.quoteCurrency()
+	conversion, err := fx.convert(ctx, series.records[0].Close, baseCurrency, currency, closestDate, opts.MaxStaleness)
 	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("error finding closest end date: %v", err)
+		return convertedRange{}, fmt.Errorf("conversion rate error: %w", err)
 	}
 
-	baseCurrency := extractBaseCurrency(internalSymbol)
+	return convertedRange{candleSeries: series, currency: currency, conversions: []Conversion{conversion}}, nil
+}
 
-	startConversionRate, startConversionRateDate, err := getConversionRateForCloseInBetween(baseCurrency, startClosestDate, startClosePrice)
+// closeRange loads the candles of internalSymbol between start and end and
+// converts each close to the quote currency of opts at its own date.
+func closeRange(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options) (convertedRange, error) {
+	series, err := fetchCandles(ctx, assetClass, internalSymbol, opts, start, end)
 	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("failed to retrieve start conversion rate: %v", err)
+		return convertedRange{}, fmt.Errorf("failed to load asset candles: %w", err)
 	}
 
-	endConversionRate, endConversionRateDate, err := getConversionRateForCloseInBetween(baseCurrency, endClosestDate, endClosePrice)
-	if err != nil {
-		return CloseInBetweenResponse{}, fmt.Errorf("failed to retrieve end conversion rate: %v", err)
+	// Resampled candles may start before the range.
+	series.records = filterRecords(series.records, start, end)
+	if len(series.records) == 0 {
+		return convertedRange{}, fmt.Errorf("%w: no candles between %s and %s", ErrNoMatch, start.Format(time.RFC3339), end.Format(time.RFC3339))
 	}
+	if err := rangeEnds(series.records[0].Time, series.records[len(series.records)-1].Time, start, end, opts); err != nil {
+		return convertedRange{}, err
+	}
+
+	baseCurrency := registry.quoteCurrency(internalSymbol)
+	currency := opts.quoteCurrency()
+	fx := converter()
 
-	startDetail := ClosePriceDetail{
-		Date:          startClosestDate.Format(time.RFC3339),
-		ClosePriceUSD: startClosePrice * startConversionRate,
-		Metadata: Metadata{
-			FetchedDate:        startClosestDate.Format(time.RFC3339),
-			ConversionRate:     startConversionRate,
-			ConversionRateDate: startConversionRateDate.Format(time.RFC3339),
-			Candle:             "1d", // Adjust as necessary
-		},
+	conversions := make([]Conversion, len(series.records))
+	for i, row := range series.records {
+		conversions[i], err = fx.convert(ctx, row.Close, baseCurrency, currency, row.Time, opts.MaxStaleness)
+		if err != nil {
+			return convertedRange{}, fmt.Errorf("failed to retrieve conversion rate for %s: %w", row.Date, err)
+		}
 	}
 
-	endDetail := ClosePriceDetail{
-		Date:          endClosestDate.Format(time.RFC3339),
-		ClosePriceUSD: endClosePrice * endConversionRate,
-		Metadata: Metadata{
-			FetchedDate:        endClosestDate.Format(time.RFC3339),
-			ConversionRate:     endConversionRate,
-			ConversionRateDate: endConversionRateDate.Format(time.RFC3339),
-			Candle:             "1d", // Adjust as necessary
-		},
+	return convertedRange{candleSeries: series, currency: currency, conversions: conversions}, nil
+}
+
+// rangeEnds checks the first and last candle of a range against the lookup
+// options. The candles of a range never lie outside it, so the policy only
+// matters when no candle falls on a bound: PolicyExact then fails, and the
+// others accept the nearest candle inside the range unless it is further
+// than MaxStaleness from the bound.
+func rangeEnds(first, last, start, end time.Time, opts Options) error {
+	for _, bound := range []struct {
+		candle, at time.Time
+	}{{first, start}, {last, end}} {
+		if bound.candle.Equal(bound.at) {
+			continue
+		}
+		if opts.policy() == PolicyExact {
+			return fmt.Errorf("%w: no candle at %s", ErrNoMatch, bound.at.Format(time.RFC3339))
+		}
+		if opts.MaxStaleness > 0 && bound.candle.Sub(bound.at).Abs() > opts.MaxStaleness {
+			return fmt.Errorf("%w: the candle nearest to %s within the range is at %s", ErrNoMatch, bound.at.Format(time.RFC3339), bound.candle.Format(time.RFC3339))
+		}
 	}
+	return nil
+}
 
-	return CloseInBetweenResponse{
-		ClosePricesUSD: []ClosePriceDetail{startDetail, endDetail},
-	}, nil
+// usdAmount returns amount when currency is USD and nil otherwise, for the
+// closePriceUSD fields kept for clients that predate quote currencies.
+func usdAmount(amount float64, currency string) *float64 {
+	if currency != "USD" {
+		return nil
+	}
+	return &amount
 }
 
 func jsonResponse(data interface{}) string {
@@ -201,188 +310,170 @@ func jsonResponse(data interface{}) string {
 	return string(jsonData)
 }
 
-func extractBaseCurrency(filename string) string {
-	// Remove the extension first
-	withoutExtension := strings.TrimSuffix(filename, ".csv")
-
-	// Split on the last underscore to hopefully get the currency as the last part
-	parts := strings.Split(withoutExtension, "_")
-	if len(parts) > 1 {
-		baseCurrency := parts[len(parts)-1] // Get the last part after the last underscore
-		if baseCurrency == "USDT" {
-			baseCurrency = "USD" // Normalize USDT to USD
-		}
-		return baseCurrency
+// unknownSymbol reports whether the DataSource is a Catalog that does not
+// list symbol under assetClass.
+func unknownSymbol(ctx context.Context, assetClass, symbol string) bool {
+	c, ok := source.(Catalog)
+	if !ok {
+		return false
 	}
-	return "USD" // Default to USD if parsing fails or no underscore is found
-}
-
-func getConversionRateForCloseInBetween(baseCurrency string, date time.Time, closePrice float64) (float64, time.Time, error) {
-
-	if baseCurrency == "USD" || baseCurrency == "USDT" {
-		return 1.0, date, nil
-	}
-
-	conversionRate, conversionRateDateFloat, _, err := getConversionRate(baseCurrency, date, closePrice)
+	symbols, err := c.Symbols(ctx, assetClass)
 	if err != nil {
-		return 0, time.Time{}, err
+		return false
 	}
-
-	// Convert conversionRateDateFloat from float64 to time.Time
-	conversionRateDate := time.Unix(int64(conversionRateDateFloat), 0)
-
-	return conversionRate, conversionRateDate, nil
+	i := sort.SearchStrings(symbols, symbol)
+	return i == len(symbols) || symbols[i] != symbol
 }
 
-func findClosestDate(data []map[string]string, requestedDate time.Time) (float64, time.Time, error) {
-	var closestDate time.Time
-	var closePrice float64
-	smallestDiff := time.Duration(1<<63 - 1) // Large initial value
+// candleSeries is a run of candles along with how they were obtained.
+type candleSeries struct {
+	records []Record
+	// interval is the interval of records.
+	interval string
+	// resampledFrom is the interval records were aggregated from, if any.
+	resampledFrom string
+}
 
-	for _, row := range data {
-		recordDate, err := time.Parse(time.RFC3339, row["Date"])
-		if err != nil {
-			continue // skip rows with invalid dates
+// fetchCandles loads symbol's candles within [from, to] from the configured
+// DataSource. A requested interval without data of its own is resampled
+// from the finest data available before other intervals are considered.
+func fetchCandles(ctx context.Context, assetClass, internalSymbol string, opts Options, from, to time.Time) (candleSeries, error) {
+	for _, interval := range opts.intervals(config) {
+		records, err := source.Candles(ctx, assetClass, internalSymbol, interval, from, to)
+		if err == nil && opts.Strict {
+			records = withoutFilledDays(records)
+			if len(records) == 0 {
+				err = fmt.Errorf("%w: only %s candles for %s/%s between %s and %s", ErrNoData, IntervalAll, assetClass, internalSymbol, from.Format(time.RFC3339), to.Format(time.RFC3339))
+			}
+		}
+		if err == nil {
+			return candleSeries{records: records, interval: interval}, nil
+		}
+		if !errors.Is(err, ErrNoData) {
+			return candleSeries{}, err
 		}
 
-		diff := requestedDate.Sub(recordDate).Abs()
-		if diff < smallestDiff {
-			smallestDiff = diff
-			closestDate = recordDate
-			closePrice, _ = strconv.ParseFloat(row["Close"], 64)
+		if interval == opts.Candle {
+			series, err := resampleCandles(ctx, assetClass, internalSymbol, interval, from, to)
+			if err == nil {
+				return series, nil
+			}
+			if !errors.Is(err, ErrNoData) {
+				return candleSeries{}, err
+			}
 		}
 	}
 
-	if smallestDiff == time.Duration(1<<63-1) {
-		return 0, time.Time{}, errors.New("no matching date found")
+	if unknownSymbol(ctx, assetClass, internalSymbol) {
+		return candleSeries{}, fmt.Errorf("%w %s/%s: %w", ErrUnknownSymbol, assetClass, internalSymbol, ErrNoData)
 	}
-
-	return closePrice, closestDate, nil
+	if opts.Strict {
+		return candleSeries{}, fmt.Errorf("%w for %s/%s at %s between %s and %s", ErrNoData, assetClass, internalSymbol, opts.Candle, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return candleSeries{}, fmt.Errorf("%w for %s/%s between %s and %s", ErrNoData, assetClass, internalSymbol, from.Format(time.RFC3339), to.Format(time.RFC3339))
 }
 
-func getConversionRate(baseCurrency string, date time.Time, rawClosePrice float64) (float64, float64, time.Time, error) {
-	if baseCurrency == "USD" || baseCurrency == "USDT" {
-		return rawClosePrice, 1.0, date, nil // Directly return for USD as no conversion is needed
+// withoutFilledDays returns records without those filling days that have
+// no file at the interval read, which a strict lookup does not accept.
+func withoutFilledDays(records []Record) []Record {
+	for i, rec := range records {
+		if !rec.fromAll {
+			continue
+		}
+		kept := append([]Record(nil), records[:i]...)
+		for _, rec := range records[i+1:] {
+			if !rec.fromAll {
+				kept = append(kept, rec)
+			}
+		}
+		return kept
 	}
+	return records
+}
 
-	// Find the path to the forex data file
-	forexFilePath, err := findForexPath(baseCurrency, date)
+// resampleCandles builds interval candles within [from, to] from the
+// coarsest finer interval that has data. The read window is widened to whole
+// candles so that the first and last candle are not truncated.
+func resampleCandles(ctx context.Context, assetClass, internalSymbol, interval string, from, to time.Time) (candleSeries, error) {
+	length, err := parseInterval(interval)
 	if err != nil {
-		return 0, 0, time.Time{}, fmt.Errorf("failed to find forex file path: %v", err)
+		return candleSeries{}, fmt.Errorf("%w: cannot resample to %s", ErrNoData, interval)
 	}
 
-	// Read the forex data from the CSV file found
-	data, err := readCSV(forexFilePath)
+	session := config.Sessions[assetClass]
+	readFrom, err := session.bucketStart(from, length)
 	if err != nil {
-		return 0, 0, time.Time{}, fmt.Errorf("failed to open forex file: %v", err)
+		return candleSeries{}, err
 	}
-
-	// Find the closest conversion rate in the forex data
-	closestDate, conversionRate, err := findClosestConversionRate(data, date)
+	lastStart, err := session.bucketStart(to, length)
 	if err != nil {
-		return 0, 0, time.Time{}, err
+		return candleSeries{}, err
 	}
+	readTo := lastStart.Add(length - time.Nanosecond)
 
-	// Calculate the close price in USD
-	closePriceUSD := rawClosePrice * conversionRate
-	return closePriceUSD, conversionRate, closestDate, nil
-}
-
-func findClosestConversionRate(data []map[string]string, targetDate time.Time) (time.Time, float64, error) {
-	var closestDate time.Time
-	var conversionRate float64
-	smallestDiff := time.Duration(1<<63 - 1)
-
-	for _, row := range data {
-		recordDate, err := time.Parse(time.RFC3339, row["Date"])
-		if err != nil {
+	for _, finer := range finerIntervals(config, interval) {
+		records, err := source.Candles(ctx, assetClass, internalSymbol, finer, readFrom, readTo)
+		if errors.Is(err, ErrNoData) {
 			continue
 		}
-		rate, err := strconv.ParseFloat(row["Close"], 64) // Changed from "ConversionRate" to "Close"
 		if err != nil {
-			fmt.Printf("Skipping row with invalid rate: '%v' at %v\n", row["Close"], row["Date"])
-			continue
+			return candleSeries{}, err
 		}
 
-		diff := targetDate.Sub(recordDate).Abs()
-		if diff < smallestDiff {
-			smallestDiff = diff
-			closestDate = recordDate
-			conversionRate = rate
+		resampled, err := Resample(records, interval, session)
+		if err != nil {
+			return candleSeries{}, err
 		}
+		resampled = filterRecords(resampled, readFrom, to)
+		if len(resampled) == 0 {
+			continue
+		}
+		return candleSeries{records: resampled, interval: interval, resampledFrom: finer}, nil
 	}
-
-	if smallestDiff == time.Duration(1<<63-1) {
-		return time.Time{}, 0, errors.New("no conversion rate found")
-	}
-
-	return closestDate, conversionRate, nil
+	return candleSeries{}, fmt.Errorf("%w: no data finer than %s for %s/%s", ErrNoData, interval, assetClass, internalSymbol)
 }
 
-func findDataPath(assetClass, internalSymbol string, date time.Time) (string, error) {
-	basePath := filepath.Join("C:\\Users\\isvan\\OneDrive\\Documents\\work\\GoApi\\data", assetClass)
-	year := date.Format("2006") // Ensure four-digit year
-	month := date.Format("01")
-	day := date.Format("02")
-
-	intervals := []string{"1m", "2m", "5m", "15m", "1h", "1w", "1d"}
-	for _, interval := range intervals {
-		path := filepath.Join(basePath, year, month, day, interval, fmt.Sprintf("%s.csv", internalSymbol))
-		fmt.Println("Checking path:", path)
-		if _, err := os.Stat(path); err == nil {
-			fmt.Println("Found file at:", path)
-			return path, nil
+// findClosestDate returns the index and date of the candle in date-sorted
+// data that answers a lookup at requestedDate under policy. Candles further
+// than maxStaleness from requestedDate are not considered when it is set.
+func findClosestDate(data []Record, requestedDate time.Time, policy LookupPolicy, maxStaleness time.Duration) (int, time.Time, error) {
+	// next is the first candle at or after requestedDate.
+	next := sort.Search(len(data), func(i int) bool { return !data[i].Time.Before(requestedDate) })
+	exact := next < len(data) && data[next].Time.Equal(requestedDate)
+
+	found := -1
+	switch policy {
+	case PolicyPrevious:
+		if exact {
+			found = next
 		} else {
-			fmt.Println("Could not find file at:", path, "; Error:", err)
+			found = next - 1
+		}
+	case PolicyNext:
+		if next < len(data) {
+			found = next
+		}
+	case PolicyExact:
+		if exact {
+			found = next
+		}
+	default:
+		found = next
+		if next == len(data) || (next > 0 && requestedDate.Sub(data[next-1].Time) <= data[next].Time.Sub(requestedDate)) {
+			found = next - 1
 		}
 	}
 
-	allPath := filepath.Join(basePath, "all", fmt.Sprintf("%s.csv", internalSymbol))
-	fmt.Println("Checking all directory path:", allPath)
-	if _, err := os.Stat(allPath); err == nil {
-		fmt.Println("Found file in all directory at:", allPath)
-		return allPath, nil
-	} else {
-		fmt.Println("Could not find file in all directory;", "Error:", err)
-	}
-
-	return "", fmt.Errorf("no valid data path found for the date: %s", date)
-}
-
-func findForexPath(baseCurrency string, date time.Time) (string, error) {
-	basePath := "C:\\Users\\isvan\\OneDrive\\Documents\\work\\GoApi\\data\\forex"
-	year := date.Format("2006")
-	month := date.Format("01")
-	day := date.Format("02")
-
-	// Target currency is always USD
-	targetCurrency := "USD"
-	fileName := fmt.Sprintf("%s_%s.csv", baseCurrency, targetCurrency)
-
-	// Intervals to check in order of priority
-	intervals := []string{"1m", "2m", "5m", "15m", "1h", "1w", "1d"}
-	for _, interval := range intervals {
-		path := filepath.Join(basePath, year, month, day, interval, fileName)
-		fmt.Println("Checking forex path:", path)
-		if _, err := os.Stat(path); err == nil {
-			fmt.Println("Found forex file at:", path)
-			return path, nil
-		} else {
-			fmt.Println("Could not find forex file at:", path, "; Error:", err)
-		}
+	if found < 0 || found >= len(data) {
+		return 0, time.Time{}, fmt.Errorf("%w: no %s candle for %s", ErrNoMatch, policy, requestedDate.Format(time.RFC3339))
 	}
 
-	// Fallback to the 'all' directory at the year level if no specific interval file is found
-	allPath := filepath.Join(basePath, "all", fileName)
-	fmt.Println("Checking forex all directory path:", allPath)
-	if _, err := os.Stat(allPath); err == nil {
-		fmt.Println("Found forex file in all directory at:", allPath)
-		return allPath, nil
-	} else {
-		fmt.Println("Could not find forex file in all directory;", "Error:", err)
+	closestDate := data[found].Time
+	if maxStaleness > 0 && requestedDate.Sub(closestDate).Abs() > maxStaleness {
+		return 0, time.Time{}, fmt.Errorf("%w: closest %s candle for %s is at %s, more than %s away", ErrNoMatch, policy, requestedDate.Format(time.RFC3339), closestDate.Format(time.RFC3339), maxStaleness)
 	}
 
-	return "", fmt.Errorf("no valid forex data path found for the date: %s", date)
+	return found, closestDate, nil
 }
 
 func GetCloseUSDIndex(assetClass, internalSymbol string, date time.Time) (CloseResult, error) {
@@ -406,10 +497,10 @@ func GetCloseUSDIndex(assetClass, internalSymbol string, date time.Time) (CloseR
 	// Parse the date from the string data retrieved.
 	closestDate, _ := time.Parse(time.RFC3339, data["Date"])
 	// Extract the base currency from the symbol name.
-	baseCurrency := extractBaseCurrency(internalSymbol)
+	baseCurrency := registry.quoteCurrency(internalSymbol)
 
 	// Calculate the USD close price using the conversion rate.
-	closePriceUSD, conversionRate, conversionRateDate, err := getConversionRate(baseCurrency, closestDate, rawClosePrice)
+	conversion, err := converter().Convert(context.Background(), rawClosePrice, baseCurrency, "USD", closestDate)
 	if err != nil {
 		// Return an error if there is a problem fetching the conversion rate.
 		return CloseResult{}, fmt.Errorf("conversion rate error: %v", err)
@@ -417,11 +508,13 @@ func GetCloseUSDIndex(assetClass, internalSymbol string, date time.Time) (CloseR
 
 	// Return a struct populated with the calculated data.
 	return CloseResult{
-		ClosePriceUSD:      closePriceUSD,
+		ClosePrice:         conversion.Amount,
+		Currency:           "USD",
+		ClosePriceUSD:      conversion.Amount,
 		RawClosePrice:      rawClosePrice,
 		FetchedDate:        closestDate.Format(time.RFC3339),
-		ConversionRate:     conversionRate,
-		ConversionRateDate: conversionRateDate.Format(time.RFC3339),
+		ConversionRate:     conversion.Rate,
+		ConversionRateDate: conversion.RateDate.Format(time.RFC3339),
 		Candle:             "1d",
 	}, nil
 }
@@ -468,33 +561,30 @@ func GetCloseInBetweenIndex(assetClass, internalSymbol, startDate, endDate strin
 	endClosestDate, _ := time.Parse(time.RFC3339, endData["Date"])
 
 	// Extract the base currency from the symbol name.
-	baseCurrency := extractBaseCurrency(internalSymbol)
+	baseCurrency := registry.quoteCurrency(internalSymbol)
 
-	// Retrieve conversion rates for the start and end close prices.
-	startConversionRate, startConversionRateDate, err := getConversionRateForCloseInBetween(baseCurrency, startClosestDate, startClosePrice)
+	// Convert the start and end close prices to USD.
+	fx := converter()
+	startConversion, err := fx.Convert(context.Background(), startClosePrice, baseCurrency, "USD", startClosestDate)
 	if err != nil {
 		return CloseRangeResult{}, fmt.Errorf("failed to retrieve start conversion rate: %v", err)
 	}
 
-	endConversionRate, endConversionRateDate, err := getConversionRateForCloseInBetween(baseCurrency, endClosestDate, endClosePrice)
+	endConversion, err := fx.Convert(context.Background(), endClosePrice, baseCurrency, "USD", endClosestDate)
 	if err != nil {
 		return CloseRangeResult{}, fmt.Errorf("failed to retrieve end conversion rate: %v", err)
 	}
 
-	// Calculate the USD prices for start and end close prices.
-	startClosePriceUSD := startClosePrice * startConversionRate
-	endClosePriceUSD := endClosePrice * endConversionRate
-
 	// Return a struct populated with the calculated data for the range.
 	return CloseRangeResult{
-		StartClosePriceUSD:      startClosePriceUSD,
-		EndClosePriceUSD:        endClosePriceUSD,
+		StartClosePriceUSD:      startConversion.Amount,
+		EndClosePriceUSD:        endConversion.Amount,
 		StartFetchedDate:        startClosestDate.Format(time.RFC3339),
 		EndFetchedDate:          endClosestDate.Format(time.RFC3339),
-		StartConversionRate:     startConversionRate,
-		StartConversionRateDate: startConversionRateDate.Format(time.RFC3339),
-		EndConversionRate:       endConversionRate,
-		EndConversionRateDate:   endConversionRateDate.Format(time.RFC3339),
+		StartConversionRate:     startConversion.Rate,
+		StartConversionRateDate: startConversion.RateDate.Format(time.RFC3339),
+		EndConversionRate:       endConversion.Rate,
+		EndConversionRateDate:   endConversion.RateDate.Format(time.RFC3339),
 		Candle:                  "1d",
 	}, nil
 }
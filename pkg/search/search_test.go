@@ -1,10 +1,67 @@
 package search
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
+// withSource makes the package level lookups read from src for the duration of the test.
+func withSource(t *testing.T, src DataSource) {
+	t.Helper()
+	prev := source
+	source = src
+	t.Cleanup(func() { source = prev })
+}
+
+// mustParse parses an RFC 3339 date of a test.
+func mustParse(date string) time.Time {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// withConfig configures the package lookups with cfg for the duration of
+// the test.
+func withConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	prevConfig, prevRegistry, prevSource, prevFiles := config, registry, source, files
+	t.Cleanup(func() {
+		config, registry, source, files = prevConfig, prevRegistry, prevSource, prevFiles
+	})
+	if err := Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetCloseUSDFromMemorySource(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 0.45},
+		Record{Date: "2024-06-01T01:00:00Z", Close: 0.46},
+	)
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T00:40:00Z")
+	result, err := GetCloseUSD(context.Background(), "crypto", "ADA_USDT", date, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.ClosePriceUSD == nil || *result.ClosePriceUSD != 0.46 {
+		t.Errorf("ClosePriceUSD = %v, want 0.46", result.ClosePriceUSD)
+	}
+	if result.Metadata.FetchedDate != "2024-06-01T01:00:00Z" {
+		t.Errorf("FetchedDate = %s, want 2024-06-01T01:00:00Z", result.Metadata.FetchedDate)
+	}
+}
+
 // func TestGetCloseUSDForwSpecificDate(t *testing.T) {
 // 	assetClass := "crypto"
 // 	internalSymbol := "ADA_USDT"
@@ -106,16 +163,136 @@ import (
 // 	}
 // }
 
+func TestGetCloseUSDReportsIntervalUsed(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "5m", Record{Date: "2024-06-01T00:05:00Z", Close: 0.45})
+	src.Add("crypto", "ADA_USDT", "1h", Record{Date: "2024-06-01T00:00:00Z", Close: 0.44})
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T00:05:00Z")
+
+	tests := []struct {
+		name     string
+		opts     Options
+		want     string
+		wantFrom string
+		wantErr  bool
+	}{
+		{name: "configured priority", opts: Options{}, want: "5m"},
+		{name: "requested interval", opts: Options{Candle: "1h"}, want: "1h"},
+		{name: "resampled", opts: Options{Candle: "1d", Strict: true}, want: "1d", wantFrom: "1h"},
+		{name: "best effort fallback", opts: Options{Candle: "3m"}, want: "5m"},
+		{name: "strict", opts: Options{Candle: "3m", Strict: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GetCloseUSD(context.Background(), "crypto", "ADA_USDT", date, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Metadata.Candle != tt.want {
+				t.Errorf("Metadata.Candle = %q, want %q", result.Metadata.Candle, tt.want)
+			}
+			if result.Metadata.ResampledFrom != tt.wantFrom {
+				t.Errorf("Metadata.ResampledFrom = %q, want %q", result.Metadata.ResampledFrom, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestFindClosestDatePolicies(t *testing.T) {
+	data := prepareRecords([]Record{
+		{Date: "2024-06-01T22:00:00Z", Close: 1},
+		{Date: "2024-06-01T23:00:00Z", Close: 2},
+		{Date: "2024-06-02T00:00:00Z", Close: 3},
+	})
+	requested, _ := time.Parse(time.RFC3339, "2024-06-01T23:59:00Z")
+
+	tests := []struct {
+		policy       LookupPolicy
+		maxStaleness time.Duration
+		want         float64
+		wantErr      bool
+	}{
+		{policy: PolicyNearest, want: 3},
+		{policy: PolicyPrevious, want: 2},
+		{policy: PolicyNext, want: 3},
+		{policy: PolicyExact, wantErr: true},
+		{policy: PolicyPrevious, maxStaleness: 30 * time.Minute, wantErr: true},
+		{policy: PolicyPrevious, maxStaleness: time.Hour, want: 2},
+	}
+
+	for _, tt := range tests {
+		i, _, err := findClosestDate(data, requested, tt.policy, tt.maxStaleness)
+		if tt.wantErr {
+			if !errors.Is(err, ErrNoMatch) {
+				t.Errorf("%s/%s: expected ErrNoMatch, got %v", tt.policy, tt.maxStaleness, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s/%s: unexpected error: %v", tt.policy, tt.maxStaleness, err)
+			continue
+		}
+		if data[i].Close != tt.want {
+			t.Errorf("%s/%s: got close %v, want %v", tt.policy, tt.maxStaleness, data[i].Close, tt.want)
+		}
+	}
+}
+
+func TestGetCloseUSDAsOfPreviousDay(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h",
+		Record{Date: "2024-06-01T23:00:00Z", Close: 0.45},
+		Record{Date: "2024-06-02T00:00:00Z", Close: 0.46},
+	)
+	withSource(t, src)
+
+	date, _ := time.Parse(time.RFC3339, "2024-06-01T23:59:00Z")
+	result, err := GetCloseUSD(context.Background(), "crypto", "ADA_USDT", date, Options{Policy: PolicyPrevious})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.ClosePriceUSD == nil || *result.ClosePriceUSD != 0.45 {
+		t.Errorf("ClosePriceUSD = %v, want 0.45", result.ClosePriceUSD)
+	}
+
+	_, err = GetCloseUSD(context.Background(), "crypto", "ADA_USDT", date, Options{Policy: PolicyPrevious, MaxStaleness: 30 * time.Minute})
+	if err == nil {
+		t.Errorf("expected an error for a candle older than the maximum staleness")
+	}
+}
+
 func TestGetCloseInBetweenJSON(t *testing.T) {
 	assetClass := "crypto"
 	internalSymbol := "ADA_USDT"
 	startDate, _ := time.Parse(time.RFC3339, "2024-06-01T00:00:00Z")
 	endDate, _ := time.Parse(time.RFC3339, "2024-06-03T00:00:00Z")
 
-	// Mock the response from GetCloseInBetween
-	expectedJSON := `{"closePricesUSD":[{"date":"2024-06-01T00:00:00Z","closePriceUSD":0.45,"metadata":{"fetchedDate":"2024-06-01T00:05:00Z","conversionRate":1,"conversionRateDate":"2024-06-01T00:00:00Z","candle":"1h"}},{"date":"2024-06-03T00:00:00Z","closePriceUSD":0.47,"metadata":{"fetchedDate":"2024-06-03T00:05:00Z","conversionRate":1,"conversionRateDate":"2024-06-03T00:00:00Z","candle":"1h"}}]}`
+	src := NewMemorySource()
+	src.Add(assetClass, internalSymbol, "1h",
+		Record{Date: "2024-05-31T23:00:00Z", Close: 0.44},
+		Record{Date: "2024-06-01T00:00:00Z", Close: 0.45},
+		Record{Date: "2024-06-02T00:00:00Z", Close: 0.46},
+		Record{Date: "2024-06-03T00:00:00Z", Close: 0.47},
+		Record{Date: "2024-06-03T01:00:00Z", Close: 0.48},
+	)
+	withSource(t, src)
+
+	closes := `[` +
+		`{"date":"2024-06-01T00:00:00Z","closePrice":0.45,"closePriceUSD":0.45,"metadata":{"fetchedDate":"2024-06-01T00:00:00Z","conversionRate":1,"conversionRateDate":"2024-06-01T00:00:00Z","candle":"1h"}},` +
+		`{"date":"2024-06-02T00:00:00Z","closePrice":0.46,"closePriceUSD":0.46,"metadata":{"fetchedDate":"2024-06-02T00:00:00Z","conversionRate":1,"conversionRateDate":"2024-06-02T00:00:00Z","candle":"1h"}},` +
+		`{"date":"2024-06-03T00:00:00Z","closePrice":0.47,"closePriceUSD":0.47,"metadata":{"fetchedDate":"2024-06-03T00:00:00Z","conversionRate":1,"conversionRateDate":"2024-06-03T00:00:00Z","candle":"1h"}}]`
+	expectedJSON := `{"currency":"USD","closePrices":` + closes + `,"closePricesUSD":` + closes + `}`
 
-	jsonResult, err := GetCloseInBetweenJSON(assetClass, internalSymbol, startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+	jsonResult, err := GetCloseInBetweenJSON(assetClass, internalSymbol, startDate, endDate, Options{Candle: "1h", Strict: true})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -123,3 +300,117 @@ func TestGetCloseInBetweenJSON(t *testing.T) {
 		t.Errorf("Expected JSON response did not match.\nExpected: %s\nGot: %s", expectedJSON, jsonResult)
 	}
 }
+
+func TestGetCloseInBetweenStaysWithinRange(t *testing.T) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1h",
+		Record{Date: "2024-06-01T00:00:00Z", Close: 0.45},
+		Record{Date: "2024-06-01T12:00:00Z", Close: 0.46},
+		Record{Date: "2024-06-02T00:00:00Z", Close: 0.47},
+	)
+	withSource(t, src)
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantDates []string
+		wantErr   error
+	}{
+		{name: "default policy", opts: Options{}, wantDates: []string{"2024-06-01T12:00:00Z"}},
+		{name: "previous", opts: Options{Policy: PolicyPrevious}, wantDates: []string{"2024-06-01T12:00:00Z"}},
+		{name: "within staleness", opts: Options{MaxStaleness: 12 * time.Hour}, wantDates: []string{"2024-06-01T12:00:00Z"}},
+		{name: "beyond staleness", opts: Options{MaxStaleness: time.Hour}, wantErr: ErrNoMatch},
+		{name: "exact", opts: Options{Policy: PolicyExact}, wantErr: ErrNoMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GetCloseInBetween(context.Background(), "crypto", "ADA_USDT", mustParse("2024-06-01T00:30:00Z"), mustParse("2024-06-01T23:59:00Z"), tt.opts)
+			stream, streamErr := StreamCandles(context.Background(), "crypto", "ADA_USDT", mustParse("2024-06-01T00:30:00Z"), mustParse("2024-06-01T23:59:00Z"), tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(streamErr, tt.wantErr) {
+					t.Errorf("errors = %v and %v, want %v", err, streamErr, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || streamErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", err, streamErr)
+			}
+
+			var dates, streamed []string
+			for _, c := range result.ClosePrices {
+				dates = append(dates, c.Date)
+			}
+			if err := stream.Closes(func(c ClosePriceDetail) error {
+				streamed = append(streamed, c.Date)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dates, tt.wantDates) || !reflect.DeepEqual(streamed, tt.wantDates) {
+				t.Errorf("dates = %v, streamed %v, want %v", dates, streamed, tt.wantDates)
+			}
+		})
+	}
+}
+
+// yearOf1m returns a year of one minute candles starting at 2024-01-01.
+func yearOf1m() []Record {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := make([]Record, 0, 366*24*60)
+	for t := start; t.Before(start.AddDate(1, 0, 0)); t = t.Add(time.Minute) {
+		records = append(records, Record{Date: t.Format(time.RFC3339), Time: t, Close: 1})
+	}
+	return records
+}
+
+func BenchmarkFindClosestDateYearOf1m(b *testing.B) {
+	data := yearOf1m()
+	requested := time.Date(2024, 7, 1, 12, 30, 30, 0, time.UTC)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := findClosestDate(data, requested, PolicyPrevious, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetCloseUSDYearOf1m(b *testing.B) {
+	src := NewMemorySource()
+	src.Add("crypto", "ADA_USDT", "1m", yearOf1m()...)
+	prev := source
+	source = src
+	defer func() { source = prev }()
+
+	ctx := context.Background()
+	requested := time.Date(2024, 7, 1, 12, 30, 30, 0, time.UTC)
+	opts := Options{Candle: "1m", Strict: true, Policy: PolicyPrevious}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetCloseUSD(ctx, "crypto", "ADA_USDT", requested, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseYearOf1mCSV measures the one-off cost of reading a year of
+// one minute candles from CSV, paid once per file rather than per lookup.
+func BenchmarkParseYearOf1mCSV(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("Date,Open,High,Low,Close,Volume\n")
+	for _, rec := range yearOf1m() {
+		sb.WriteString(rec.Date + ",1,1,1,1,100\n")
+	}
+	path := filepath.Join(b.TempDir(), "ADA_USDT.csv")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := readCSV(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
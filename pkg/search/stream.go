@@ -0,0 +1,194 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CandleStream walks the candles of a range lookup one day at a time, so
+// that exports of long ranges hold a day of candles in memory rather than
+// the whole range.
+type CandleStream struct {
+	// Currency is the quote currency of the prices.
+	Currency string
+	// Candle is the interval of the candles.
+	Candle string
+
+	ctx            context.Context
+	assetClass     string
+	internalSymbol string
+	opts           Options
+	first, last    time.Time
+	// step is the length of the windows the stream is read in: a day, or
+	// a candle when candles longer than a day are resampled, so that each
+	// read builds one candle rather than the whole of it for every day.
+	step time.Duration
+}
+
+// StreamCandles prepares a stream of the candles of internalSymbol between
+// start and end, the candles GetCandles returns for the same range. The
+// first and last candle are looked up here, so that errors such as an
+// unknown symbol, no data or a missing rate are returned before anything is
+// streamed; the candles in between are read at the interval of the first.
+func StreamCandles(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options) (*CandleStream, error) {
+	if err := checkRange(start, end); err != nil {
+		return nil, err
+	}
+
+	first, err := rangeEdge(ctx, assetClass, internalSymbol, start, end, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	pinned := opts
+	pinned.Candle, pinned.Strict = first.interval, true
+	last, err := rangeEdge(ctx, assetClass, internalSymbol, start, end, pinned, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := rangeEnds(first.records[0].Time, last.records[0].Time, start, end, opts); err != nil {
+		return nil, err
+	}
+
+	step := 24 * time.Hour
+	if first.resampledFrom != "" {
+		if length, err := parseInterval(first.interval); err == nil && length > step {
+			step = length
+		}
+	}
+
+	currency := opts.quoteCurrency()
+	row := first.records[0]
+	if _, err := converter().convert(ctx, row.Close, registry.quoteCurrency(internalSymbol), currency, row.Time, opts.MaxStaleness); err != nil {
+		return nil, fmt.Errorf("failed to retrieve conversion rate for %s: %w", row.Date, err)
+	}
+
+	return &CandleStream{
+		Currency:       currency,
+		Candle:         first.interval,
+		ctx:            ctx,
+		assetClass:     assetClass,
+		internalSymbol: internalSymbol,
+		opts:           pinned,
+		first:          row.Time,
+		last:           last.records[0].Time,
+		step:           step,
+	}, nil
+}
+
+// rangeEdge returns the first candle of internalSymbol within [start, end]
+// or, when last is set, the last one, reading the range a day at a time
+// from that end.
+func rangeEdge(ctx context.Context, assetClass, internalSymbol string, start, end time.Time, opts Options, last bool) (candleSeries, error) {
+	const day = 24 * time.Hour
+	firstDay, lastDay := start.UTC().Truncate(day), end.UTC().Truncate(day)
+	found := false
+	for i := time.Duration(0); !firstDay.Add(i * day).After(lastDay); i++ {
+		from := firstDay.Add(i * day)
+		if last {
+			from = lastDay.Add(-i * day)
+		}
+		to := from.Add(day - time.Nanosecond)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+
+		series, err := fetchCandles(ctx, assetClass, internalSymbol, opts, from, to)
+		if errors.Is(err, ErrNoData) && !errors.Is(err, ErrUnknownSymbol) {
+			continue
+		}
+		if err != nil {
+			return candleSeries{}, fmt.Errorf("failed to load asset candles: %w", err)
+		}
+		found = true
+
+		// Resampled candles may start before the range.
+		records := filterRecords(series.records, start, end)
+		if len(records) == 0 {
+			continue
+		}
+		if last {
+			series.records = records[len(records)-1:]
+		} else {
+			series.records = records[:1]
+		}
+		return series, nil
+	}
+
+	if !found {
+		return candleSeries{}, fmt.Errorf("failed to load asset candles: %w for %s/%s between %s and %s", ErrNoData, assetClass, internalSymbol, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return candleSeries{}, fmt.Errorf("%w: no candles between %s and %s", ErrNoMatch, start.Format(time.RFC3339), end.Format(time.RFC3339))
+}
+
+// Candles calls fn with every candle of the stream in date order, stopping
+// at the first error.
+func (s *CandleStream) Candles(fn func(CandleDetail) error) error {
+	baseCurrency := registry.quoteCurrency(s.internalSymbol)
+	fx := converter()
+
+	// Resampled candles start at s.first and every step after it; others
+	// are read by calendar day.
+	window := s.first
+	if s.step == 24*time.Hour {
+		window = s.first.UTC().Truncate(s.step)
+	}
+
+	var emitted time.Time
+	for ; !window.After(s.last); window = window.Add(s.step) {
+		from, to := window, window.Add(s.step-time.Nanosecond)
+		if from.Before(s.first) {
+			from = s.first
+		}
+		if to.After(s.last) {
+			to = s.last
+		}
+
+		series, err := fetchCandles(s.ctx, s.assetClass, s.internalSymbol, s.opts, from, to)
+		if errors.Is(err, ErrNoData) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, row := range series.records {
+			// A resampled candle is returned by every window it overlaps.
+			if row.Time.Before(s.first) || row.Time.After(s.last) || (!emitted.IsZero() && !row.Time.After(emitted)) {
+				continue
+			}
+			emitted = row.Time
+
+			conversion, err := fx.convert(s.ctx, row.Close, baseCurrency, s.Currency, row.Time, s.opts.MaxStaleness)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve conversion rate for %s: %w", row.Date, err)
+			}
+			rng := convertedRange{
+				candleSeries: candleSeries{records: []Record{row}, interval: series.interval, resampledFrom: series.resampledFrom},
+				currency:     s.Currency,
+				conversions:  []Conversion{conversion},
+			}
+			if err := fn(rng.detail(0)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Closes calls fn with the close of every candle of the stream, as
+// GetCloseInBetween reports it.
+func (s *CandleStream) Closes(fn func(ClosePriceDetail) error) error {
+	return s.Candles(func(c CandleDetail) error {
+		return fn(ClosePriceDetail{
+			Date:          c.Date,
+			ClosePrice:    c.Close,
+			ClosePriceUSD: usdAmount(c.Close, s.Currency),
+			Metadata:      c.Metadata,
+		})
+	})
+}
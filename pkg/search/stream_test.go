@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCandleStreamMatchesGetCandles(t *testing.T) {
+	src := NewMemorySource()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 24*21; h += 6 {
+		date := start.Add(time.Duration(h) * time.Hour)
+		if date.Day() == 5 {
+			continue // a day without candles
+		}
+		src.Add("crypto", "ADA_USDT", "1h", Record{Date: date.Format(time.RFC3339), High: float64(h) + 1, Low: float64(h), Close: float64(h) + 0.5, Volume: 1})
+	}
+	withSource(t, src)
+
+	tests := []struct {
+		name               string
+		startDate, endDate string
+		opts               Options
+	}{
+		{name: "hourly", startDate: "2024-06-01T03:00:00Z", endDate: "2024-06-09T20:00:00Z", opts: Options{}},
+		{name: "resampled weekly", startDate: "2024-06-01T00:00:00Z", endDate: "2024-06-20T00:00:00Z", opts: Options{Candle: "1w", Policy: PolicyPrevious}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := GetCandles(context.Background(), "crypto", "ADA_USDT", mustParse(tt.startDate), mustParse(tt.endDate), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream, err := StreamCandles(context.Background(), "crypto", "ADA_USDT", mustParse(tt.startDate), mustParse(tt.endDate), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []CandleDetail
+			if err := stream.Candles(func(c CandleDetail) error {
+				got = append(got, c)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if stream.Currency != want.Currency || !reflect.DeepEqual(got, want.Candles) {
+				t.Errorf("streamed %d candles in %s, want %d in %s:\n%+v\n%+v", len(got), stream.Currency, len(want.Candles), want.Currency, got, want.Candles)
+			}
+		})
+	}
+}
+
+func TestCandleStreamReadsResampledCandlesOnce(t *testing.T) {
+	mem := NewMemorySource()
+	start := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC) // a Monday
+	var records []Record
+	for h := 0; h < 4*7*24; h++ {
+		records = append(records, Record{Date: start.Add(time.Duration(h) * time.Hour).Format(time.RFC3339), Close: 1})
+	}
+	mem.Add("crypto", "ADA_USDT", "1h", records...)
+	src := &countingSource{DataSource: mem, calls: make(map[string]int)}
+	withSource(t, src)
+
+	opts := Options{Candle: "1w", Strict: true}
+	if _, err := fetchCandles(context.Background(), "crypto", "ADA_USDT", opts, start, start.Add(7*24*time.Hour-time.Nanosecond)); err != nil {
+		t.Fatal(err)
+	}
+	perWeek := src.calls["crypto"]
+
+	stream, err := StreamCandles(context.Background(), "crypto", "ADA_USDT", mustParse("2024-06-03T00:00:00Z"), mustParse("2024-06-30T23:00:00Z"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.calls["crypto"] = 0
+	n := 0
+	if err := stream.Candles(func(CandleDetail) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("streamed %d weekly candles, want 4", n)
+	}
+	if got := src.calls["crypto"]; got != 4*perWeek {
+		t.Errorf("read the series %d times for 4 weekly candles, want %d", got, 4*perWeek)
+	}
+}
+
+func TestStreamCandlesReportsErrorsUpFront(t *testing.T) {
+	withSource(t, NewMemorySource())
+
+	_, err := StreamCandles(context.Background(), "crypto", "NOPE_USDT", mustParse("2024-06-01T00:00:00Z"), mustParse("2024-06-02T00:00:00Z"), Options{})
+	if !errors.Is(err, ErrUnknownSymbol) {
+		t.Errorf("error = %v, want ErrUnknownSymbol", err)
+	}
+}
@@ -1,6 +1,9 @@
 package search
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 func directoryExists(path string) bool {
 	info, err := os.Stat(path)
@@ -9,3 +12,9 @@ func directoryExists(path string) bool {
 	}
 	return info.IsDir()
 }
+
+// dayBounds returns the first and last instant of the UTC day containing t.
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := t.UTC().Truncate(24 * time.Hour)
+	return start, start.Add(24*time.Hour - time.Nanosecond)
+}